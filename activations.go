@@ -0,0 +1,221 @@
+package goras
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"sync"
+
+	G "gorgonia.org/gorgonia"
+)
+
+// ActivationFunc applies an activation function to x, for the given activation layer.
+// The layer is passed in so that the function can read its settings, and so that any constants it creates can be named after the layer (node names must be unique in a graph).
+type ActivationFunc func(l *ActivationLayer, x *G.Node) (*G.Node, error)
+
+var (
+	activationsLock sync.RWMutex
+	activations     = map[string]ActivationFunc{
+		"sigmoid": func(_ *ActivationLayer, x *G.Node) (*G.Node, error) { return G.Sigmoid(x) },
+		"relu":    func(_ *ActivationLayer, x *G.Node) (*G.Node, error) { return G.Rectify(x) },
+		"tanh":    func(_ *ActivationLayer, x *G.Node) (*G.Node, error) { return G.Tanh(x) },
+		"binary": func(l *ActivationLayer, x *G.Node) (*G.Node, error) {
+			return G.Gt(x, G.NewConstant(defaultVal(x.Dtype()), G.WithType(x.Dtype()), G.WithName(fmt.Sprintf("%s.binarythresh", l.Name()))), true)
+		},
+		"softmax": func(l *ActivationLayer, x *G.Node) (*G.Node, error) {
+			return customSoftMax(x, l.Axis, false)
+		},
+		"leakyrelu": func(l *ActivationLayer, x *G.Node) (*G.Node, error) {
+			//on, err = G.LeakyRelu(n, l.LeakyReluGrad)
+			return customLeakyRelu(x, l.LeakyReluGrad, l.Name()) // TODO: my custom leakyrelu seems to be working but gorgonias dosn't. Invistigate more and maybe create an issue.
+		},
+		"gelu":        gelu,
+		"swish":       swish,
+		"silu":        swish,
+		"elu":         func(l *ActivationLayer, x *G.Node) (*G.Node, error) { return elu(x, 1, l.Name()+".elu") },
+		"selu":        selu,
+		"softplus":    softplus,
+		"softsign":    softsign,
+		"hardsigmoid": hardSigmoid,
+		"logsoftmax": func(l *ActivationLayer, x *G.Node) (*G.Node, error) {
+			return customSoftMax(x, l.Axis, true)
+		},
+	}
+)
+
+// RegisterActivation adds a new activation function, which can then be used by name in Activation.
+// It returns an error if there is already an activation with that name.
+func RegisterActivation(name string, fn ActivationFunc) error {
+	if fn == nil {
+		return fmt.Errorf("cannot register a nil activation function for '%s'", name)
+	}
+	activationsLock.Lock()
+	defer activationsLock.Unlock()
+	if _, ok := activations[name]; ok {
+		return fmt.Errorf("there is already an activation called '%s'", name)
+	}
+	activations[name] = fn
+	return nil
+}
+
+// MustRegisterActivation calls RegisterActivation, but panics if there is an error.
+func MustRegisterActivation(name string, fn ActivationFunc) {
+	if err := RegisterActivation(name, fn); err != nil {
+		panic(err)
+	}
+}
+
+// RegisteredActivations returns the names of all activations that can be used in Activation, in alphabetical order.
+func RegisteredActivations() []string {
+	activationsLock.RLock()
+	defer activationsLock.RUnlock()
+	names := make([]string, 0, len(activations))
+	for name := range activations {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func getActivation(name string) (ActivationFunc, bool) {
+	activationsLock.RLock()
+	defer activationsLock.RUnlock()
+	fn, ok := activations[name]
+	return fn, ok
+}
+
+// scalarConstant makes a scalar constant with the same dtype as x.
+func scalarConstant(x *G.Node, v float64, name string) *G.Node {
+	return G.NewConstant(castVal(x.Dtype(), v), G.WithName(name))
+}
+
+// gelu uses the tanh approximation 0.5x(1 + tanh(sqrt(2/pi)(x + 0.044715x^3))).
+func gelu(l *ActivationLayer, x *G.Node) (*G.Node, error) {
+	cubed, err := G.Cube(x)
+	if err != nil {
+		return nil, err
+	}
+	inner, err := G.Mul(cubed, scalarConstant(x, 0.044715, l.Name()+".gelu_cube_scale"))
+	if err != nil {
+		return nil, err
+	}
+	if inner, err = G.Add(x, inner); err != nil {
+		return nil, err
+	}
+	if inner, err = G.Mul(inner, scalarConstant(x, math.Sqrt(2/math.Pi), l.Name()+".gelu_scale")); err != nil {
+		return nil, err
+	}
+	if inner, err = G.Tanh(inner); err != nil {
+		return nil, err
+	}
+	if inner, err = G.Add(inner, scalarConstant(x, 1, l.Name()+".gelu_one")); err != nil {
+		return nil, err
+	}
+	if inner, err = G.HadamardProd(x, inner); err != nil {
+		return nil, err
+	}
+	return G.Mul(inner, scalarConstant(x, 0.5, l.Name()+".gelu_half"))
+}
+
+// swish is x * sigmoid(x), which is also called silu.
+func swish(_ *ActivationLayer, x *G.Node) (*G.Node, error) {
+	sig, err := G.Sigmoid(x)
+	if err != nil {
+		return nil, err
+	}
+	return G.HadamardProd(x, sig)
+}
+
+// elu is x for x > 0, and alpha(exp(x) - 1) otherwise.
+// It is calculated as relu(x) + alpha(exp(-relu(-x)) - 1), so that exp is never given a large positive number.
+func elu(x *G.Node, alpha float64, name string) (*G.Node, error) {
+	pos, err := G.Rectify(x)
+	if err != nil {
+		return nil, err
+	}
+	neg, err := G.Neg(x)
+	if err != nil {
+		return nil, err
+	}
+	if neg, err = G.Rectify(neg); err != nil {
+		return nil, err
+	}
+	if neg, err = G.Neg(neg); err != nil {
+		return nil, err
+	}
+	if neg, err = G.Expm1(neg); err != nil {
+		return nil, err
+	}
+	if neg, err = G.Mul(neg, scalarConstant(x, alpha, name+"_alpha")); err != nil {
+		return nil, err
+	}
+	return G.Add(pos, neg)
+}
+
+// selu is scale * elu(x, alpha), with the constants from the paper "Self-Normalizing Neural Networks".
+func selu(l *ActivationLayer, x *G.Node) (*G.Node, error) {
+	const alpha, scale = 1.6732632423543772848170429916717, 1.0507009873554804934193349852946
+	e, err := elu(x, alpha, l.Name()+".selu")
+	if err != nil {
+		return nil, err
+	}
+	return G.Mul(e, scalarConstant(x, scale, l.Name()+".selu_scale"))
+}
+
+// softplus is log(1 + exp(x)).
+// It is calculated as relu(x) + log(1 + exp(-|x|)), so that exp is never given a large positive number.
+func softplus(_ *ActivationLayer, x *G.Node) (*G.Node, error) {
+	pos, err := G.Rectify(x)
+	if err != nil {
+		return nil, err
+	}
+	rest, err := G.Abs(x)
+	if err != nil {
+		return nil, err
+	}
+	if rest, err = G.Neg(rest); err != nil {
+		return nil, err
+	}
+	if rest, err = G.Exp(rest); err != nil {
+		return nil, err
+	}
+	if rest, err = G.Log1p(rest); err != nil {
+		return nil, err
+	}
+	return G.Add(pos, rest)
+}
+
+// softsign is x / (1 + |x|).
+func softsign(l *ActivationLayer, x *G.Node) (*G.Node, error) {
+	denom, err := G.Abs(x)
+	if err != nil {
+		return nil, err
+	}
+	if denom, err = G.Add(denom, scalarConstant(x, 1, l.Name()+".softsign_one")); err != nil {
+		return nil, err
+	}
+	return G.HadamardDiv(x, denom)
+}
+
+// hardSigmoid is a piecewise linear approximation of sigmoid, clip(0.2x + 0.5, 0, 1).
+// It is calculated as relu(y) - relu(y - 1), where y = 0.2x + 0.5.
+func hardSigmoid(l *ActivationLayer, x *G.Node) (*G.Node, error) {
+	y, err := G.Mul(x, scalarConstant(x, 0.2, l.Name()+".hardsigmoid_slope"))
+	if err != nil {
+		return nil, err
+	}
+	if y, err = G.Add(y, scalarConstant(x, 0.5, l.Name()+".hardsigmoid_offset")); err != nil {
+		return nil, err
+	}
+	over, err := G.Sub(y, scalarConstant(x, 1, l.Name()+".hardsigmoid_one"))
+	if err != nil {
+		return nil, err
+	}
+	if over, err = G.Rectify(over); err != nil {
+		return nil, err
+	}
+	if y, err = G.Rectify(y); err != nil {
+		return nil, err
+	}
+	return G.Sub(y, over)
+}
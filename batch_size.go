@@ -0,0 +1,171 @@
+package goras
+
+import (
+	"fmt"
+	"reflect"
+
+	G "gorgonia.org/gorgonia"
+)
+
+// This file contains the code that lets a built model predict with a different batch size to the one it was built with.
+// The batch size is part of the shape of every node, so the model is rebuilt on a new graph for each new batch size, by attaching a copy of each layer in the same way as the original.
+// These copies are cached, and the parameters of the original model are bound to them each time they are run, so they always use the latest parameters.
+
+// initialStatesAttacher is a layer that can be attached with initial states, such as the recurrent layers.
+type initialStatesAttacher interface {
+	AttachWithInitialStates(x *G.Node, initialStates ...*G.Node) (*G.Node, error)
+}
+
+// modelForBatchSize returns a copy of the model that runs with the given batch size, which shares the parameters of m.
+// The copy is built the first time a batch size is used, and reused after that. If it cannot be built, the error is returned every time that batch size is used, without trying again.
+// The copy can only be used for predicting, so it has no loss.
+func (m *Model) modelForBatchSize(batchSize int) (*Model, error) {
+	if batchSize == m.getCurrentBatchSize() {
+		return m, nil
+	}
+	if bm, ok := m.batchSizeModels[batchSize]; ok {
+		return bm, nil
+	}
+	if err, ok := m.batchSizeErrors[batchSize]; ok {
+		return nil, err
+	}
+	bm, err := m.rebuildWithBatchSize(batchSize)
+	if err != nil {
+		err = fmt.Errorf("cannot run the model with a batch size of %v: %v", batchSize, err)
+		if m.batchSizeErrors == nil {
+			m.batchSizeErrors = make(map[int]error)
+		}
+		m.batchSizeErrors[batchSize] = err
+		return nil, err
+	}
+	if m.batchSizeModels == nil {
+		m.batchSizeModels = make(map[int]*Model)
+	}
+	m.batchSizeModels[batchSize] = bm
+	return bm, nil
+}
+
+// rebuildWithBatchSize builds a copy of the model on a new graph, where every input has the given batch size.
+// The layers are attached in an order where the inputs of each layer are attached before it, which may not be the order they were added in.
+func (m *Model) rebuildWithBatchSize(batchSize int) (*Model, error) {
+	bm := &Model{Graph: G.NewGraph(), Layers: []Layer{}, DefaultInitializer: m.DefaultInitializer}
+	// The layers pass masks to each other while they are attached, but there is no loss to use them after that
+	defer forgetMasks(bm.Graph)
+	// Maps the nodes in the original graph to the equivalent nodes in the new graph
+	nodes := make(map[*G.Node]*G.Node)
+	remaining := []Layer{}
+	for _, l := range m.Layers {
+		if l.Node() != nil {
+			remaining = append(remaining, l)
+		}
+	}
+	for len(remaining) > 0 {
+		var blocked []Layer
+		for _, l := range remaining {
+			inputs, ok := mapNodes(nodes, l.INodes())
+			if !ok {
+				blocked = append(blocked, l)
+				continue
+			}
+			on, err := reattachLayer(bm, l, inputs, batchSize)
+			if err != nil {
+				return nil, fmt.Errorf("layer %s: %v", l.Name(), err)
+			}
+			nodes[l.Node()] = on
+		}
+		if len(blocked) == len(remaining) {
+			// Any layers left over depend on nodes that are not the output of a layer, but they may not be needed by any of the outputs
+			break
+		}
+		remaining = blocked
+	}
+
+	bm.InputNodes = make(map[string]*G.Node, len(m.InputNodes))
+	for name, n := range m.InputNodes {
+		bn, ok := nodes[n]
+		if !ok {
+			return nil, fmt.Errorf("input %s is not the output of an input layer", name)
+		}
+		bm.InputNodes[name] = bn
+	}
+	bm.OutputNodes = make(map[string]*G.Node, len(m.OutputNodes))
+	bm.OutputValues = make(map[string]*G.Value, len(m.OutputNodes))
+	for name, n := range m.OutputNodes {
+		bn, ok := nodes[n]
+		if !ok {
+			return nil, fmt.Errorf("output %s does not only depend on layers (any other gorgonia operations can be wrapped in a Lambda layer)", name)
+		}
+		bm.OutputNodes[name] = bn
+		var val G.Value
+		G.Read(bn, &val)
+		bm.OutputValues[name] = &val
+	}
+	bm.Machine = G.NewTapeMachine(bm.Graph)
+	return bm, nil
+}
+
+// reattachLayer adds a copy of the layer l to the model bm, and attaches it to inputs, which are nodes in the graph of bm.
+// Input layers are recreated with the new batch size instead.
+func reattachLayer(bm *Model, l Layer, inputs []*G.Node, batchSize int) (*G.Node, error) {
+	if il, ok := l.(*InputLayer); ok {
+		shape := il.Node().Shape().Clone()
+		shape[0] = batchSize
+		return Input(bm, il.Name(), il.Node().Dtype(), shape...).Node(), nil
+	}
+	if ll, ok := l.(*LambdaLayer); ok && len(ll.Params) > 0 {
+		// The function of the layer uses the parameter nodes directly, so it cannot be pointed at the new ones
+		return nil, fmt.Errorf("lambda layers with parameters cannot be rebuilt")
+	}
+	lc, err := copyLayer(l, bm.Graph)
+	if err != nil {
+		return nil, err
+	}
+	bm.AddLayer(lc)
+	switch lc := lc.(type) {
+	case initialStatesAttacher:
+		return lc.AttachWithInitialStates(inputs[0], inputs[1:]...)
+	case multiAttacher:
+		return lc.Attach(inputs...)
+	case attacher:
+		if len(inputs) != 1 {
+			return nil, fmt.Errorf("expected the layer to have 1 input but it has %v", len(inputs))
+		}
+		return lc.Attach(inputs[0])
+	default:
+		return nil, fmt.Errorf("layer type %T has no Attach method", lc)
+	}
+}
+
+// copyLayer makes a shallow copy of l which has not been attached yet, and which will attach to the graph g.
+// The settings of the layer (e.g. the number of units) are shared with the original layer, but attaching the copy creates new nodes.
+func copyLayer(l Layer, g *G.ExprGraph) (Layer, error) {
+	v := reflect.ValueOf(l)
+	if v.Kind() != reflect.Pointer || v.Elem().Kind() != reflect.Struct {
+		return nil, fmt.Errorf("layer type %T cannot be copied, as it is not a pointer to a struct", l)
+	}
+	c := reflect.New(v.Elem().Type())
+	c.Elem().Set(v.Elem())
+	lc := c.Interface().(Layer)
+	bl, ok := lc.(baseLayer)
+	if !ok {
+		return nil, fmt.Errorf("layer type %T cannot be copied, as it does not embed LayerBase", l)
+	}
+	base := bl.layerBase()
+	base.Graph = g
+	base.OutputNode = nil
+	base.InputNodes = nil
+	return lc, nil
+}
+
+// mapNodes returns the equivalent of each node in ns, or false if any of them do not have one.
+func mapNodes(nodes map[*G.Node]*G.Node, ns []*G.Node) ([]*G.Node, bool) {
+	mapped := make([]*G.Node, len(ns))
+	for i, n := range ns {
+		mn, ok := nodes[n]
+		if !ok {
+			return nil, false
+		}
+		mapped[i] = mn
+	}
+	return mapped, true
+}
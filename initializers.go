@@ -0,0 +1,220 @@
+package goras
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// The weight initializers below can be set as the Initializer of any layer with weights, or as the DefaultInitializer of a model.
+// Any G.InitWFn can also be used, so it is easy to write your own.
+//
+// The fan in and fan out of a weight are worked out from its shape:
+//   - (n,): fan in and fan out are both n
+//   - (inputs, outputs): as used by dense and recurrent layers
+//   - (outputs, inputs, ...kernel_size): as used by convolutional layers, where both fans are multiplied by the kernel volume
+//
+// Conv2DTranspose stores its kernels as (inputs, outputs, ...kernel_size), so it gives its initializer the shape with the first two axes swapped, then swaps them back.
+
+// GlorotNormal samples weights from a normal distribution with standard deviation sqrt(2 / (fan_in + fan_out)).
+// This is the default initializer.
+func GlorotNormal() G.InitWFn {
+	return func(dt T.Dtype, s ...int) interface{} {
+		fanIn, fanOut := calculateFans(s)
+		return randomValues(dt, s, normalSampler(math.Sqrt(2/(fanIn+fanOut))))
+	}
+}
+
+// GlorotUniform samples weights from a uniform distribution in [-sqrt(6 / (fan_in + fan_out)), sqrt(6 / (fan_in + fan_out))].
+func GlorotUniform() G.InitWFn {
+	return func(dt T.Dtype, s ...int) interface{} {
+		fanIn, fanOut := calculateFans(s)
+		return randomValues(dt, s, uniformSampler(math.Sqrt(6/(fanIn+fanOut))))
+	}
+}
+
+// HeNormal samples weights from a normal distribution with standard deviation sqrt(2 / fan_in).
+// This works well for layers followed by a relu.
+func HeNormal() G.InitWFn {
+	return func(dt T.Dtype, s ...int) interface{} {
+		fanIn, _ := calculateFans(s)
+		return randomValues(dt, s, normalSampler(math.Sqrt(2/fanIn)))
+	}
+}
+
+// HeUniform samples weights from a uniform distribution in [-sqrt(6 / fan_in), sqrt(6 / fan_in)].
+// This works well for layers followed by a relu.
+func HeUniform() G.InitWFn {
+	return func(dt T.Dtype, s ...int) interface{} {
+		fanIn, _ := calculateFans(s)
+		return randomValues(dt, s, uniformSampler(math.Sqrt(6/fanIn)))
+	}
+}
+
+// LeCunNormal samples weights from a normal distribution with standard deviation sqrt(1 / fan_in).
+// This works well for layers followed by a selu.
+func LeCunNormal() G.InitWFn {
+	return func(dt T.Dtype, s ...int) interface{} {
+		fanIn, _ := calculateFans(s)
+		return randomValues(dt, s, normalSampler(math.Sqrt(1/fanIn)))
+	}
+}
+
+// LeCunUniform samples weights from a uniform distribution in [-sqrt(3 / fan_in), sqrt(3 / fan_in)].
+func LeCunUniform() G.InitWFn {
+	return func(dt T.Dtype, s ...int) interface{} {
+		fanIn, _ := calculateFans(s)
+		return randomValues(dt, s, uniformSampler(math.Sqrt(3/fanIn)))
+	}
+}
+
+// Zeros sets all weights to 0.
+func Zeros() G.InitWFn { return Constant(0) }
+
+// Ones sets all weights to 1.
+func Ones() G.InitWFn { return Constant(1) }
+
+// Constant sets all weights to val.
+func Constant(val float64) G.InitWFn {
+	return func(dt T.Dtype, s ...int) interface{} {
+		return randomValues(dt, s, func() float64 { return val })
+	}
+}
+
+// Orthogonal creates a random orthogonal matrix, multiplied by gain.
+// The weight is treated as a matrix with shape (shape[0], product of the other dims). If it has more rows than columns, the columns are orthonormal, otherwise the rows are.
+// This is often used for the recurrent kernels of recurrent layers.
+func Orthogonal(gain float64) G.InitWFn {
+	return func(dt T.Dtype, s ...int) interface{} {
+		if len(s) < 2 {
+			panic(fmt.Sprintf("orthogonal initializer needs at least 2 dims but got shape %v", s))
+		}
+		rows, cols := s[0], T.Shape(s[1:]).TotalSize()
+		// Make a tall matrix with orthonormal columns, then transpose it if we wanted a wide one
+		tallRows, tallCols := max(rows, cols), min(rows, cols)
+		tall := make([]float64, tallRows*tallCols)
+		for i := range tall {
+			tall[i] = rand.NormFloat64()
+		}
+		gramSchmidt(tall, tallRows, tallCols)
+		i := 0
+		return randomValues(dt, s, func() float64 {
+			r, c := i/cols, i%cols
+			i++
+			if rows >= cols {
+				return gain * tall[r*tallCols+c]
+			}
+			return gain * tall[c*tallCols+r]
+		})
+	}
+}
+
+// gramSchmidt makes the columns of the row-major (rows, cols) matrix orthonormal in place. rows must be at least cols.
+func gramSchmidt(m []float64, rows, cols int) {
+	for c := 0; c < cols; c++ {
+		for prev := 0; prev < c; prev++ {
+			dot := 0.0
+			for r := 0; r < rows; r++ {
+				dot += m[r*cols+c] * m[r*cols+prev]
+			}
+			for r := 0; r < rows; r++ {
+				m[r*cols+c] -= dot * m[r*cols+prev]
+			}
+		}
+		norm := 0.0
+		for r := 0; r < rows; r++ {
+			norm += m[r*cols+c] * m[r*cols+c]
+		}
+		norm = math.Sqrt(norm)
+		for r := 0; r < rows; r++ {
+			m[r*cols+c] /= norm
+		}
+	}
+}
+
+// calculateFans works out the fan in and fan out of a weight with the given shape.
+func calculateFans(s []int) (float64, float64) {
+	switch len(s) {
+	case 0:
+		panic("cannot initialise a scalar weight with a fan based initializer")
+	case 1:
+		return float64(s[0]), float64(s[0])
+	case 2:
+		return float64(s[0]), float64(s[1])
+	default:
+		kernelVolume := T.Shape(s[2:]).TotalSize()
+		return float64(s[1] * kernelVolume), float64(s[0] * kernelVolume)
+	}
+}
+
+// inputsFirstInit wraps init for weights with the shape (inputs, outputs, ...kernel_size), such as the kernels of Conv2DTranspose.
+// init is called with the shape (outputs, inputs, ...kernel_size), so that it works out the fans correctly, and the first two axes of the values it returns are swapped.
+func inputsFirstInit(init G.InitWFn) G.InitWFn {
+	return func(dt T.Dtype, s ...int) interface{} {
+		if len(s) < 3 {
+			return init(dt, s...)
+		}
+		swapped := append([]int{s[1], s[0]}, s[2:]...)
+		switch vals := init(dt, swapped...).(type) {
+		case []float64:
+			return swapFirstAxes(vals, s)
+		case []float32:
+			return swapFirstAxes(vals, s)
+		default:
+			// The values cannot be rearranged, but for most initializers every value is sampled the same way, so the order does not matter
+			return vals
+		}
+	}
+}
+
+// swapFirstAxes takes values with the shape (s[1], s[0], ...rest), and returns them rearranged to the shape (s[0], s[1], ...rest).
+func swapFirstAxes[F float32 | float64](vals []F, s []int) []F {
+	rest := T.Shape(s[2:]).TotalSize()
+	swapped := make([]F, len(vals))
+	for i := 0; i < s[0]; i++ {
+		for j := 0; j < s[1]; j++ {
+			copy(swapped[(i*s[1]+j)*rest:(i*s[1]+j+1)*rest], vals[(j*s[0]+i)*rest:(j*s[0]+i+1)*rest])
+		}
+	}
+	return swapped
+}
+
+func normalSampler(stddev float64) func() float64 {
+	return func() float64 { return rand.NormFloat64() * stddev }
+}
+
+func uniformSampler(limit float64) func() float64 {
+	return func() float64 { return (rand.Float64()*2 - 1) * limit }
+}
+
+// randomValues creates a slice of the given dtype with one value from sample for each element of the shape.
+func randomValues(dt T.Dtype, s []int, sample func() float64) interface{} {
+	size := T.Shape(s).TotalSize()
+	switch dt {
+	case T.Float64:
+		vals := make([]float64, size)
+		for i := range vals {
+			vals[i] = sample()
+		}
+		return vals
+	case T.Float32:
+		vals := make([]float32, size)
+		for i := range vals {
+			vals[i] = float32(sample())
+		}
+		return vals
+	default:
+		panic(fmt.Sprintf("initializers can only be used with float64 and float32, not %v", dt))
+	}
+}
+
+// initializerOrDefault returns init, or GlorotNormal if init is nil.
+func initializerOrDefault(init G.InitWFn) G.InitWFn {
+	if init == nil {
+		return GlorotNormal()
+	}
+	return init
+}
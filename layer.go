@@ -2,6 +2,7 @@ package goras
 
 import (
 	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
 )
 
 // Layer is an interface that all layers must implement to be able to be added to a model.
@@ -14,6 +15,44 @@ type Layer interface {
 	INodes() []*G.Node              // This returns all nodes used as inputs to this layer
 }
 
+// RegularizedLayer is a layer that adds extra terms to the loss, such as a penalty on the size of its weights.
+// Model.Build adds these terms to the loss automatically.
+type RegularizedLayer interface {
+	// RegularizationTerms returns the scalar nodes to add to the loss, keyed by a name that is unique within the layer (e.g. "kernel").
+	RegularizationTerms() (map[string]*G.Node, error)
+}
+
+// legacyParamsLayer is a layer that has changed the way it stores its parameters, but can still load parameters saved in the old format.
+type legacyParamsLayer interface {
+	// upgradeParams converts any of the layer's parameters in params from the old format to the new format, in place.
+	upgradeParams(params map[string]*T.Dense) error
+}
+
+// StatefulLayer is a layer that also has some non-trainable state, such as the running statistics of a batch norm layer.
+// This state is saved and loaded along with the parameters, but is never updated by the solver.
+type StatefulLayer interface {
+	Layer
+	State() map[string]*T.Dense // This returns a map of the state tensors. E.g. {"running_mean":[...]}
+}
+
+// TrainModeLayer is a layer that behaves differently while training and while predicting.
+// The model will call SetTraining(true) before each call to FitBatch and SetTraining(false) before each call to PredictBatch.
+// The current mode of the model can also be checked with Model.IsTraining.
+type TrainModeLayer interface {
+	Layer
+	SetTraining(isTraining bool) error
+}
+
+// trainableSetter is a layer that can be frozen and unfrozen. Every layer that embeds LayerBase is one.
+type trainableSetter interface {
+	SetTrainable(trainable bool)
+}
+
+// baseLayer is a layer that embeds LayerBase. Every layer in goras is one.
+type baseLayer interface {
+	layerBase() *LayerBase
+}
+
 // LayerBase is a struct that all layers should embed.
 // It provides some useful shared fields and methods.
 type LayerBase struct {
@@ -40,6 +79,16 @@ func (l *LayerBase) Trainable() bool {
 	return l.IsTrainable
 }
 
+// SetTrainable sets whether the layer is trainable. This is usually done with Model.SetTrainable.
+func (l *LayerBase) SetTrainable(trainable bool) {
+	l.IsTrainable = trainable
+}
+
+// layerBase returns the LayerBase itself, so that it can be changed when a layer is copied.
+func (l *LayerBase) layerBase() *LayerBase {
+	return l
+}
+
 // Node returns the final node in this layer (the output node)
 func (l *LayerBase) Node() *G.Node {
 	return l.OutputNode
@@ -62,3 +111,15 @@ func mustAttach(l attacher, x *G.Node) *G.Node {
 	}
 	return n
 }
+
+type multiAttacher interface {
+	Attach(...*G.Node) (*G.Node, error)
+}
+
+func mustAttachMulti(l multiAttacher, xs ...*G.Node) *G.Node {
+	n, err := l.Attach(xs...)
+	if err != nil {
+		panic(err)
+	}
+	return n
+}
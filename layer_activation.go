@@ -13,12 +13,15 @@ type ActivationLayer struct {
 	LayerBase
 	Activation    string
 	LeakyReluGrad float64
+	Axis          int // The axis that softmax and logsoftmax are applied along. Negative axes count from the end.
 }
 
 // Activation creates a new ActivationLayer on the Model with the given activation function.
-// The activation function can be one of ["sigmoid", "relu", "tanh", "binary", "softmax", "leakyrelu"].
+// The activation function can be any registered activation. The built in ones are
+// ["sigmoid", "relu", "tanh", "binary", "softmax", "leakyrelu", "gelu", "swish", "silu", "elu", "selu", "softplus", "softsign", "hardsigmoid", "logsoftmax"],
+// and more can be added with RegisterActivation.
 func Activation(m *Model, name string, activation string) *ActivationLayer {
-	a := &ActivationLayer{LayerBase{m.Graph, name, "activation(" + activation + ")", false, nil, nil}, activation, 0.01}
+	a := &ActivationLayer{LayerBase{m.Graph, name, "activation(" + activation + ")", false, nil, nil}, activation, 0.01, -1}
 	m.AddLayer(a)
 	return a
 }
@@ -44,8 +47,14 @@ func Binary(m *Model, name string) *ActivationLayer {
 }
 
 // Softmax creates a new ActivationLayer on the Model with the softmax activation function.
-func Softmax(m *Model, name string) *ActivationLayer {
-	return Activation(m, name, "softmax")
+// You can optionally specify the axis to apply the softmax along (Softmax(model, name, axis)).
+// If you don't, it will default to -1 (the last axis).
+func Softmax(m *Model, name string, axis ...int) *ActivationLayer {
+	a := Activation(m, name, "softmax")
+	if len(axis) > 0 {
+		a.Axis = axis[0]
+	}
+	return a
 }
 
 // LeakyRelu creates a new ActivationLayer on the Model with the leaky relu activation function.
@@ -59,31 +68,70 @@ func LeakyRelu(m *Model, name string, grad ...float64) *ActivationLayer {
 	return a
 }
 
+// GELU creates a new ActivationLayer on the Model with the gelu activation function.
+// This uses the tanh approximation of gelu.
+func GELU(m *Model, name string) *ActivationLayer {
+	return Activation(m, name, "gelu")
+}
+
+// Swish creates a new ActivationLayer on the Model with the swish (also known as silu) activation function.
+func Swish(m *Model, name string) *ActivationLayer {
+	return Activation(m, name, "swish")
+}
+
+// SiLU creates a new ActivationLayer on the Model with the silu (also known as swish) activation function.
+func SiLU(m *Model, name string) *ActivationLayer {
+	return Activation(m, name, "silu")
+}
+
+// ELU creates a new ActivationLayer on the Model with the elu activation function, with an alpha of 1.
+func ELU(m *Model, name string) *ActivationLayer {
+	return Activation(m, name, "elu")
+}
+
+// SELU creates a new ActivationLayer on the Model with the selu activation function.
+func SELU(m *Model, name string) *ActivationLayer {
+	return Activation(m, name, "selu")
+}
+
+// Softplus creates a new ActivationLayer on the Model with the softplus activation function.
+func Softplus(m *Model, name string) *ActivationLayer {
+	return Activation(m, name, "softplus")
+}
+
+// Softsign creates a new ActivationLayer on the Model with the softsign activation function.
+func Softsign(m *Model, name string) *ActivationLayer {
+	return Activation(m, name, "softsign")
+}
+
+// HardSigmoid creates a new ActivationLayer on the Model with the hard sigmoid activation function.
+func HardSigmoid(m *Model, name string) *ActivationLayer {
+	return Activation(m, name, "hardsigmoid")
+}
+
+// LogSoftmax creates a new ActivationLayer on the Model with the log softmax activation function.
+// This is more numerically stable than using a log after a softmax.
+// You can optionally specify the axis to apply it along (LogSoftmax(model, name, axis)).
+// If you don't, it will default to -1 (the last axis).
+func LogSoftmax(m *Model, name string, axis ...int) *ActivationLayer {
+	a := Activation(m, name, "logsoftmax")
+	if len(axis) > 0 {
+		a.Axis = axis[0]
+	}
+	return a
+}
+
 // Attach attaches this layer to a previous node.
 func (l *ActivationLayer) Attach(n *G.Node) (*G.Node, error) {
-	var on *G.Node
-	var err error
-	switch l.Activation {
-	case "sigmoid":
-		on, err = G.Sigmoid(n)
-	case "relu":
-		on, err = G.Rectify(n)
-	case "tanh":
-		on, err = G.Tanh(n)
-	case "binary":
-		on, err = G.Gt(n, G.NewConstant(defaultVal(n.Dtype()), G.WithType(n.Dtype()), G.WithName(fmt.Sprintf("%s.binarythresh", l.Name()))), true)
-	case "softmax":
-		on, err = customSoftMax(n) //G.SoftMax(n, 1) // TODO: my custom softmax seems to be working but gorgonias dosn't. Invistigate more and maybe create an issue.
-	case "leakyrelu":
-		//return nil, fmt.Errorf("leakyrelu is currently broken, please just use relu for now.")
-		//on, err = G.LeakyRelu(n, l.LeakyReluGrad)
-		on, err = customLeakyRelu(n, l.LeakyReluGrad, l.Name()) // TODO: my custom leakyrelu seems to be working but gorgonias dosn't. Invistigate more and maybe create an issue.
-	default:
+	activation, ok := getActivation(l.Activation)
+	if !ok {
 		return nil, fmt.Errorf("invalid activation '%s'", l.Activation)
 	}
+	on, err := activation(l, n)
 	l.OutputNode = on
 	if on != nil {
 		G.WithName(l.Name() + ".activation")(on)
+		passMask(n, on)
 	}
 	l.InputNodes = []*G.Node{n}
 	return on, err
@@ -104,28 +152,76 @@ func defaultVal(dtype T.Dtype) interface{} {
 	}
 }
 
+func oneVal(dtype T.Dtype) interface{} {
+	switch dtype {
+	case T.Float64:
+		return float64(1.0)
+	case T.Float32:
+		return float32(1.0)
+	case T.Int:
+		return int(1)
+	case T.Bool:
+		return true
+	default:
+		panic("type is not implemented to be one vallable. please open an issue so i will fix")
+	}
+}
+
 // MustAttach attaches this layer to a previous node. It panics on error.
 func (l *ActivationLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
 
 // Parameters returns a map of the parameters of the layer.
 func (l *ActivationLayer) Parameters() map[string]*G.Node { return make(map[string]*G.Node) }
 
-// This function is designed to be a drop in replacement for G.SoftMax.
-// This is to try and find the dreaded softmax panic.
-// It will also only do stuff on axis 1
-// Also, this is probably slower than the built in softmax function as it uses mutiple nodes.
-// TODO: i think that gorgonia might have fixed the softmax issue. Investigate more.
-func customSoftMax(x *G.Node) (*G.Node, error) {
-	var err error
-	exponentiatedClasses, err := G.Exp(x)
+// customSoftMax is a drop in replacement for G.SoftMax, which works along any axis (negative axes count from the end).
+// The max along the axis is subtracted before exponentiating, so large inputs do not overflow to +Inf.
+// If log is true, it calculates log(softmax(x)) without ever taking the log of a number that has underflowed to 0.
+// The input is reshaped to (outer, axis, inner) first, which lets the same broadcasting work for any number of dimensions.
+func customSoftMax(x *G.Node, axis int, log bool) (*G.Node, error) {
+	shape := x.Shape().Clone()
+	if axis < 0 {
+		axis += len(shape)
+	}
+	if axis < 0 || axis >= len(shape) {
+		return nil, fmt.Errorf("softmax axis is out of range for input shape %v", shape)
+	}
+	x3, err := reshape(x, T.Shape{T.Shape(shape[:axis]).TotalSize(), shape[axis], T.Shape(shape[axis+1:]).TotalSize()})
+	if err != nil {
+		return nil, err
+	}
+	maxes, err := G.Max(x3, 1)
 	if err != nil {
 		return nil, err
 	}
-	summedExponentiatedClasses, err := G.Sum(exponentiatedClasses, 1)
+	shifted, err := G.BroadcastSub(x3, maxes, nil, []byte{1})
 	if err != nil {
 		return nil, err
 	}
-	return G.BroadcastHadamardDiv(exponentiatedClasses, summedExponentiatedClasses, []byte{}, []byte{1})
+	exps, err := G.Exp(shifted)
+	if err != nil {
+		return nil, err
+	}
+	sums, err := G.Sum(exps, 1)
+	if err != nil {
+		return nil, err
+	}
+	var out *G.Node
+	if log {
+		logSums, err := G.Log(sums)
+		if err != nil {
+			return nil, err
+		}
+		out, err = G.BroadcastSub(shifted, logSums, nil, []byte{1})
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		out, err = G.BroadcastHadamardDiv(exps, sums, nil, []byte{1})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return reshape(out, shape)
 }
 
 // IMPORTANT:CURRENTLY BROKEN
@@ -0,0 +1,269 @@
+package goras
+
+import (
+	"fmt"
+	"math"
+
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// MultiHeadAttentionLayer is a multi-head scaled dot-product attention layer, like the one in "Attention Is All You Need".
+// Attach takes the query, key and value nodes in that order, and an optional mask. If only one node is given, it is used for all three (self-attention).
+// The mask should be 1 where a query may attend to a key, and 0 where it may not.
+// If the keys come from a Masking layer, padded keys are also never attended to, and the output has the same mask as the query.
+//   - Query Shape: (batch_size, query_length, query_dim)
+//   - Key Shape: (batch_size, key_length, key_dim)
+//   - Value Shape: (batch_size, key_length, value_dim)
+//   - Mask Shape (optional): (batch_size, query_length, key_length)
+//   - Output Shape: (batch_size, query_length, query_dim)
+type MultiHeadAttentionLayer struct {
+	LayerBase
+	QueryKernel         *G.Node
+	QueryBias           *G.Node
+	KeyKernel           *G.Node
+	KeyBias             *G.Node
+	ValueKernel         *G.Node
+	ValueBias           *G.Node
+	OutputKernel        *G.Node
+	OutputBias          *G.Node
+	NumHeads            int
+	KeyDim              int
+	ValueDim            int
+	DropoutProbability  float64
+	Initializer         G.InitWFn   // Used for all of the kernels
+	KernelRegularizer   Regularizer // Used for all of the kernels
+	ActivityRegularizer Regularizer
+	dropoutOp           *dropoutOp
+}
+
+// MultiHeadAttention creates a new multi-head attention layer on the specified model.
+// Each head projects the queries and keys to keyDim dims. The values are also projected to keyDim dims, but this can be changed by setting ValueDim.
+// There is no dropout by default, but this can be changed by setting DropoutProbability. Dropout is applied to the attention weights, and only while training.
+// The kernels are initialised with the model's default initializer. This can be changed by setting Initializer before calling Attach.
+func MultiHeadAttention(m *Model, name string, numHeads, keyDim int) *MultiHeadAttentionLayer {
+	if numHeads < 1 || keyDim < 1 {
+		panic("numHeads and keyDim must be greater than 0")
+	}
+	l := &MultiHeadAttentionLayer{
+		LayerBase:   LayerBase{m.Graph, name, "multiheadattention", true, nil, nil},
+		NumHeads:    numHeads,
+		KeyDim:      keyDim,
+		ValueDim:    keyDim,
+		Initializer: m.DefaultInitializer,
+	}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the layer to the query, key, value and (optional) mask nodes, or to a single node for self-attention.
+func (l *MultiHeadAttentionLayer) Attach(ns ...*G.Node) (*G.Node, error) {
+	var query, key, value, mask *G.Node
+	switch len(ns) {
+	case 1:
+		query, key, value = ns[0], ns[0], ns[0]
+	case 3:
+		query, key, value = ns[0], ns[1], ns[2]
+	case 4:
+		query, key, value, mask = ns[0], ns[1], ns[2], ns[3]
+	default:
+		return nil, fmt.Errorf("multi-head attention needs 1 (self-attention), 3 (query, key, value) or 4 (query, key, value, mask) inputs but got %v", len(ns))
+	}
+	names := []string{"query", "key", "value"}
+	for i, n := range []*G.Node{query, key, value} {
+		if err := validateShape(n.Shape(), valNDims(3), valNthDim(0, query.Shape()[0])); err != nil {
+			return nil, fmt.Errorf("%v: %v", names[i], err)
+		}
+	}
+	if err := validateShape(value.Shape(), valNthDim(1, key.Shape()[1])); err != nil {
+		return nil, fmt.Errorf("value must have the same length as key: %v", err)
+	}
+	batchSize, queryLen, keyLen := query.Shape()[0], query.Shape()[1], key.Shape()[1]
+	if mask != nil {
+		if err := validateShape(mask.Shape(), valMatchingDim(T.Shape{batchSize, queryLen, keyLen})); err != nil {
+			return nil, fmt.Errorf("mask: %v", err)
+		}
+	}
+	dt := query.Dtype()
+	queryDim := query.Shape()[2]
+	newProjection := func(name string, inDim, outDim int) (*G.Node, *G.Node) {
+		kernel := G.NewMatrix(l.Graph, dt, G.WithShape(inDim, outDim), G.WithInit(initializerOrDefault(l.Initializer)), G.WithName(l.Name()+"."+name+"_kernel"))
+		bias := G.NewVector(l.Graph, dt, G.WithShape(outDim), G.WithInit(G.Zeroes()), G.WithName(l.Name()+"."+name+"_bias"))
+		return kernel, bias
+	}
+	l.QueryKernel, l.QueryBias = newProjection("query", queryDim, l.NumHeads*l.KeyDim)
+	l.KeyKernel, l.KeyBias = newProjection("key", key.Shape()[2], l.NumHeads*l.KeyDim)
+	l.ValueKernel, l.ValueBias = newProjection("value", value.Shape()[2], l.NumHeads*l.ValueDim)
+	l.OutputKernel, l.OutputBias = newProjection("output", l.NumHeads*l.ValueDim, queryDim)
+
+	// Project and split into heads, each of these is (batch_size*num_heads, length, head_dim)
+	q, err := l.projectHeads(query, l.QueryKernel, l.QueryBias, l.KeyDim)
+	if err != nil {
+		return nil, err
+	}
+	k, err := l.projectHeads(key, l.KeyKernel, l.KeyBias, l.KeyDim)
+	if err != nil {
+		return nil, err
+	}
+	v, err := l.projectHeads(value, l.ValueKernel, l.ValueBias, l.ValueDim)
+	if err != nil {
+		return nil, err
+	}
+	scale := G.NewConstant(castVal(dt, 1/math.Sqrt(float64(l.KeyDim))), G.WithName(l.Name()+".scale"))
+	q, err = G.Mul(q, scale)
+	if err != nil {
+		return nil, err
+	}
+	// scores is (batch_size*num_heads, query_length, key_length)
+	scores, err := G.BatchedMatMul(q, k, false, true)
+	if err != nil {
+		return nil, err
+	}
+	// Padded keys from a Masking layer are never attended to
+	if keyMask := sequenceMask(key); keyMask != nil {
+		mask, err = l.withKeyMask(mask, keyMask, queryLen)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if mask != nil {
+		scores, err = l.applyMask(scores, mask)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// Softmax over the keys
+	weights, err := customSoftMax(scores, 2, false)
+	if err != nil {
+		return nil, err
+	}
+	if l.DropoutProbability > 0 {
+		l.dropoutOp = newDropoutOp(l.Name(), l.DropoutProbability)
+		weights, err = G.ApplyOp(l.dropoutOp, weights)
+		if err != nil {
+			return nil, err
+		}
+	}
+	// attended is (batch_size*num_heads, query_length, value_dim)
+	attended, err := G.BatchedMatMul(weights, v)
+	if err != nil {
+		return nil, err
+	}
+	// Join the heads back together and project to the output
+	attended, err = reshape(attended, T.Shape{batchSize, l.NumHeads, queryLen, l.ValueDim})
+	if err != nil {
+		return nil, err
+	}
+	attended, err = G.Transpose(attended, 0, 2, 1, 3)
+	if err != nil {
+		return nil, err
+	}
+	attended, err = reshape(attended, T.Shape{batchSize * queryLen, l.NumHeads * l.ValueDim})
+	if err != nil {
+		return nil, err
+	}
+	on, err := affine(attended, l.OutputKernel, l.OutputBias)
+	if err != nil {
+		return nil, err
+	}
+	on, err = reshape(on, T.Shape{batchSize, queryLen, queryDim})
+	if err != nil {
+		return nil, err
+	}
+	G.WithName(l.Name() + ".attention")(on)
+	passMask(query, on)
+	l.OutputNode = on
+	l.InputNodes = ns
+	return on, nil
+}
+
+// projectHeads projects x (batch_size, length, dim) to (batch_size*num_heads, length, headDim).
+func (l *MultiHeadAttentionLayer) projectHeads(x, kernel, bias *G.Node, headDim int) (*G.Node, error) {
+	batchSize, length, dim := x.Shape()[0], x.Shape()[1], x.Shape()[2]
+	flat, err := reshape(x, T.Shape{batchSize * length, dim})
+	if err != nil {
+		return nil, err
+	}
+	projected, err := affine(flat, kernel, bias)
+	if err != nil {
+		return nil, err
+	}
+	projected, err = reshape(projected, T.Shape{batchSize, length, l.NumHeads, headDim})
+	if err != nil {
+		return nil, err
+	}
+	projected, err = G.Transpose(projected, 0, 2, 1, 3)
+	if err != nil {
+		return nil, err
+	}
+	return reshape(projected, T.Shape{batchSize * l.NumHeads, length, headDim})
+}
+
+// withKeyMask combines the (batch_size, key_length) mask of the keys with the attention mask, which may be nil, giving a (batch_size, query_length, key_length) mask.
+func (l *MultiHeadAttentionLayer) withKeyMask(mask, keyMask *G.Node, queryLen int) (*G.Node, error) {
+	if mask == nil {
+		ones := T.New(T.WithShape(keyMask.Shape()[0], queryLen, keyMask.Shape()[1]), T.Of(keyMask.Dtype()))
+		if err := ones.Memset(oneVal(keyMask.Dtype())); err != nil {
+			return nil, err
+		}
+		// This is not a constant, as broadcasting needs both nodes to be in the graph
+		mask = G.NewTensor(l.Graph, keyMask.Dtype(), 3, G.WithShape(ones.Shape()...), G.WithValue(ones), G.WithName(l.Name()+".mask_ones"))
+	}
+	// The same key mask is used for every query
+	return G.BroadcastHadamardProd(mask, keyMask, nil, []byte{1})
+}
+
+// applyMask adds a large negative number to the scores wherever the mask is 0, so that the softmax gives them a weight of zero.
+func (l *MultiHeadAttentionLayer) applyMask(scores, mask *G.Node) (*G.Node, error) {
+	batchSize, queryLen, keyLen := mask.Shape()[0], mask.Shape()[1], mask.Shape()[2]
+	one := G.NewConstant(castVal(mask.Dtype(), 1), G.WithName(l.Name()+".mask_one"))
+	big := G.NewConstant(castVal(mask.Dtype(), 1e9), G.WithName(l.Name()+".mask_big"))
+	penalty, err := G.Sub(mask, one)
+	if err != nil {
+		return nil, err
+	}
+	penalty, err = G.Mul(penalty, big)
+	if err != nil {
+		return nil, err
+	}
+	scores, err = reshape(scores, T.Shape{batchSize, l.NumHeads, queryLen, keyLen})
+	if err != nil {
+		return nil, err
+	}
+	// The same mask is used for every head
+	scores, err = G.BroadcastAdd(scores, penalty, nil, []byte{1})
+	if err != nil {
+		return nil, err
+	}
+	return reshape(scores, T.Shape{batchSize * l.NumHeads, queryLen, keyLen})
+}
+
+// MustAttach attaches the layer to the query, key, value and (optional) mask nodes, or to a single node for self-attention. It panics on error.
+func (l *MultiHeadAttentionLayer) MustAttach(ns ...*G.Node) *G.Node { return mustAttachMulti(l, ns...) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *MultiHeadAttentionLayer) Parameters() map[string]*G.Node {
+	return map[string]*G.Node{
+		"query_kernel":  l.QueryKernel,
+		"query_bias":    l.QueryBias,
+		"key_kernel":    l.KeyKernel,
+		"key_bias":      l.KeyBias,
+		"value_kernel":  l.ValueKernel,
+		"value_bias":    l.ValueBias,
+		"output_kernel": l.OutputKernel,
+		"output_bias":   l.OutputBias,
+	}
+}
+
+// RegularizationTerms returns the terms that the regularizers of the layer add to the loss.
+func (l *MultiHeadAttentionLayer) RegularizationTerms() (map[string]*G.Node, error) {
+	return layerRegularizationTerms(l.KernelRegularizer, []*G.Node{l.QueryKernel, l.KeyKernel, l.ValueKernel, l.OutputKernel}, l.ActivityRegularizer, l.OutputNode)
+}
+
+// SetTraining sets whether the attention dropout is active.
+func (l *MultiHeadAttentionLayer) SetTraining(isTraining bool) error {
+	if l.dropoutOp == nil {
+		return nil
+	}
+	return l.dropoutOp.SetTraining(isTraining)
+}
@@ -0,0 +1,200 @@
+package goras
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// RandomFlipLayer is a data augmentation layer that randomly flips each image in the batch while training.
+// While predicting, it does nothing.
+//   - Input/Output Shape: (batch_size, num_channels, img_height, img_width)
+type RandomFlipLayer struct {
+	LayerBase
+	Mode      string
+	Seed      int64 // The seed for the flips. If it is 0 (the default), the current time is used.
+	augmentOp *augmentOp
+}
+
+// RandomFlip creates a new RandomFlipLayer on the Model.
+// The mode can be one of ["horizontal", "vertical", "horizontal_and_vertical"]. Each allowed flip happens to each image with a probability of 0.5.
+// The seed can be set with the Seed field before calling Attach.
+func RandomFlip(m *Model, name string, mode string) *RandomFlipLayer {
+	l := &RandomFlipLayer{LayerBase{m.Graph, name, "randomflip(" + mode + ")", false, nil, nil}, mode, 0, nil}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the RandomFlipLayer to the given node.
+func (l *RandomFlipLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(4)); err != nil {
+		return nil, err
+	}
+	var horizontal, vertical bool
+	switch l.Mode {
+	case "horizontal":
+		horizontal = true
+	case "vertical":
+		vertical = true
+	case "horizontal_and_vertical":
+		horizontal, vertical = true, true
+	default:
+		return nil, fmt.Errorf("random flip mode must be one of 'horizontal', 'vertical' or 'horizontal_and_vertical', got '%v'", l.Mode)
+	}
+	on, op, err := attachAugmentation(l.Name(), x, x.Shape(), l.Seed, func(rng *rand.Rand, inShape T.Shape, training bool) augmentation {
+		if !training {
+			return augmentation{}
+		}
+		h, w := inShape[2], inShape[3]
+		return augmentation{index: imageIndex(inShape, h, w, func(int) func(y, x int) (int, int) {
+			flipH, flipV := horizontal && rng.Intn(2) == 0, vertical && rng.Intn(2) == 0
+			return func(y, x int) (int, int) {
+				if flipV {
+					y = h - 1 - y
+				}
+				if flipH {
+					x = w - 1 - x
+				}
+				return y, x
+			}
+		})}
+	})
+	l.augmentOp = op
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, err
+}
+
+// MustAttach attaches the RandomFlipLayer to the given node. It panics on error.
+func (l *RandomFlipLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *RandomFlipLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
+
+// SetTraining sets whether the flips are active.
+func (l *RandomFlipLayer) SetTraining(isTraining bool) error {
+	if l.augmentOp == nil {
+		return nil
+	}
+	return l.augmentOp.SetTraining(isTraining)
+}
+
+// RandomCropLayer is a data augmentation layer that crops a random area of each image in the batch while training.
+// As the output must always be the same size, it crops the centre of each image while predicting.
+//   - Input Shape: (batch_size, num_channels, img_height, img_width)
+//   - Output Shape: (batch_size, num_channels, height, width)
+type RandomCropLayer struct {
+	LayerBase
+	Height    int
+	Width     int
+	Seed      int64 // The seed for the crops. If it is 0 (the default), the current time is used.
+	augmentOp *augmentOp
+}
+
+// RandomCrop creates a new RandomCropLayer on the Model, which crops images to the given height and width.
+// The seed can be set with the Seed field before calling Attach.
+func RandomCrop(m *Model, name string, height, width int) *RandomCropLayer {
+	l := &RandomCropLayer{LayerBase{m.Graph, name, "randomcrop", false, nil, nil}, height, width, 0, nil}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the RandomCropLayer to the given node.
+func (l *RandomCropLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(4)); err != nil {
+		return nil, err
+	}
+	inH, inW, outH, outW := x.Shape()[2], x.Shape()[3], l.Height, l.Width
+	if outH < 1 || outW < 1 || outH > inH || outW > inW {
+		return nil, fmt.Errorf("cannot randomly crop images of shape %v to %vx%v", x.Shape(), outH, outW)
+	}
+	outShape := T.Shape{x.Shape()[0], x.Shape()[1], outH, outW}
+	on, op, err := attachAugmentation(l.Name(), x, outShape, l.Seed, func(rng *rand.Rand, inShape T.Shape, training bool) augmentation {
+		return augmentation{index: imageIndex(inShape, outH, outW, func(int) func(y, x int) (int, int) {
+			top, left := (inH-outH)/2, (inW-outW)/2
+			if training {
+				top, left = rng.Intn(inH-outH+1), rng.Intn(inW-outW+1)
+			}
+			return func(y, x int) (int, int) { return y + top, x + left }
+		})}
+	})
+	l.augmentOp = op
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, err
+}
+
+// MustAttach attaches the RandomCropLayer to the given node. It panics on error.
+func (l *RandomCropLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *RandomCropLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
+
+// SetTraining sets whether the crops are random (training) or from the centre (predicting).
+func (l *RandomCropLayer) SetTraining(isTraining bool) error {
+	if l.augmentOp == nil {
+		return nil
+	}
+	return l.augmentOp.SetTraining(isTraining)
+}
+
+// RandomTranslationLayer is a data augmentation layer that randomly moves each image in the batch while training, filling the space left behind with zeros.
+// While predicting, it does nothing.
+//   - Input/Output Shape: (batch_size, num_channels, img_height, img_width)
+type RandomTranslationLayer struct {
+	LayerBase
+	HeightFactor float64 // Images are moved up or down by up to this fraction of their height
+	WidthFactor  float64 // Images are moved left or right by up to this fraction of their width
+	Seed         int64   // The seed for the translations. If it is 0 (the default), the current time is used.
+	augmentOp    *augmentOp
+}
+
+// RandomTranslation creates a new RandomTranslationLayer on the Model.
+// Each image is moved by a whole number of pixels, up to heightFactor*img_height vertically and widthFactor*img_width horizontally. The factors must be in [0, 1].
+// The seed can be set with the Seed field before calling Attach.
+func RandomTranslation(m *Model, name string, heightFactor, widthFactor float64) *RandomTranslationLayer {
+	l := &RandomTranslationLayer{LayerBase{m.Graph, name, "randomtranslation", false, nil, nil}, heightFactor, widthFactor, 0, nil}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the RandomTranslationLayer to the given node.
+func (l *RandomTranslationLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(4)); err != nil {
+		return nil, err
+	}
+	if l.HeightFactor < 0 || l.HeightFactor > 1 || l.WidthFactor < 0 || l.WidthFactor > 1 {
+		return nil, fmt.Errorf("random translation factors must be in [0, 1], got %v and %v", l.HeightFactor, l.WidthFactor)
+	}
+	maxY, maxX := int(math.Floor(l.HeightFactor*float64(x.Shape()[2]))), int(math.Floor(l.WidthFactor*float64(x.Shape()[3])))
+	on, op, err := attachAugmentation(l.Name(), x, x.Shape(), l.Seed, func(rng *rand.Rand, inShape T.Shape, training bool) augmentation {
+		if !training {
+			return augmentation{}
+		}
+		return augmentation{index: imageIndex(inShape, inShape[2], inShape[3], func(int) func(y, x int) (int, int) {
+			dy, dx := rng.Intn(2*maxY+1)-maxY, rng.Intn(2*maxX+1)-maxX
+			return func(y, x int) (int, int) { return y - dy, x - dx }
+		})}
+	})
+	l.augmentOp = op
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, err
+}
+
+// MustAttach attaches the RandomTranslationLayer to the given node. It panics on error.
+func (l *RandomTranslationLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *RandomTranslationLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
+
+// SetTraining sets whether the translations are active.
+func (l *RandomTranslationLayer) SetTraining(isTraining bool) error {
+	if l.augmentOp == nil {
+		return nil
+	}
+	return l.augmentOp.SetTraining(isTraining)
+}
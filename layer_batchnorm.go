@@ -0,0 +1,103 @@
+package goras
+
+import (
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// BatchNormLayer is a batch normalisation layer. It normalises over every axis apart from axis 1 (the channels axis).
+// While training it uses the statistics of the current batch, and keeps a running mean and variance which are used when predicting.
+//   - Input/Output Shape: (batch_size, num_channels, ...other_dims) [up to 4 dims in total]
+type BatchNormLayer struct {
+	LayerBase
+	Gamma       *G.Node
+	Beta        *G.Node
+	RunningMean *T.Dense
+	RunningVar  *T.Dense
+	Momentum    float64
+	Epsilon     float64
+	// GammaInitializer and BetaInitializer are Ones and Zeros by default. Initialising gamma to zero can help train deep residual networks.
+	GammaInitializer G.InitWFn
+	BetaInitializer  G.InitWFn
+	batchNormOp      *batchNormOp
+}
+
+// BatchNorm creates a new batch normalisation layer on the specified model.
+// It has a momentum of 0.99 and an epsilon of 0.001. These can be changed by setting the fields before calling Attach.
+func BatchNorm(m *Model, name string) *BatchNormLayer {
+	l := &BatchNormLayer{
+		LayerBase:        LayerBase{m.Graph, name, "batchnorm", true, nil, nil},
+		Momentum:         0.99,
+		Epsilon:          0.001,
+		GammaInitializer: Ones(),
+		BetaInitializer:  Zeros(),
+	}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the layer to a previous node.
+func (l *BatchNormLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valAtLeastNDims(2), valAtMostNDims(4)); err != nil {
+		return nil, err
+	}
+	channels := x.Shape()[1]
+	l.Gamma = G.NewVector(l.Graph, x.Dtype(), G.WithShape(channels), G.WithInit(l.GammaInitializer), G.WithName(l.Name()+".gamma"))
+	l.Beta = G.NewVector(l.Graph, x.Dtype(), G.WithShape(channels), G.WithInit(l.BetaInitializer), G.WithName(l.Name()+".beta"))
+	l.RunningMean = T.New(T.WithShape(channels), T.Of(x.Dtype()))
+	l.RunningVar = T.New(T.WithShape(channels), T.Of(x.Dtype()))
+	if err := l.RunningVar.Memset(oneVal(x.Dtype())); err != nil {
+		return nil, err
+	}
+	l.batchNormOp = &batchNormOp{
+		name:        l.Name(),
+		dims:        x.Dims(),
+		momentum:    l.Momentum,
+		epsilon:     l.Epsilon,
+		runningMean: l.RunningMean,
+		runningVar:  l.RunningVar,
+		training:    true,
+	}
+	// Gamma and beta are broadcast along every axis that is not the channel axis
+	pattern := []byte{0}
+	for i := 2; i < x.Dims(); i++ {
+		pattern = append(pattern, byte(i))
+	}
+	normed, err := G.ApplyOp(l.batchNormOp, x)
+	if err != nil {
+		return nil, err
+	}
+	scaled, err := G.BroadcastHadamardProd(normed, l.Gamma, nil, pattern)
+	if err != nil {
+		return nil, err
+	}
+	on, err := G.BroadcastAdd(scaled, l.Beta, nil, pattern)
+	if err != nil {
+		return nil, err
+	}
+	G.WithName(l.Name() + ".batchnorm")(on)
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, nil
+}
+
+// MustAttach attaches the layer to a previous node. It panics on error.
+func (l *BatchNormLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *BatchNormLayer) Parameters() map[string]*G.Node {
+	return map[string]*G.Node{"gamma": l.Gamma, "beta": l.Beta}
+}
+
+// State returns the running statistics of the layer.
+func (l *BatchNormLayer) State() map[string]*T.Dense {
+	return map[string]*T.Dense{"running_mean": l.RunningMean, "running_var": l.RunningVar}
+}
+
+// SetTraining sets whether the layer uses the batch statistics (training) or the running statistics (predicting).
+func (l *BatchNormLayer) SetTraining(isTraining bool) error {
+	if l.batchNormOp == nil {
+		return nil
+	}
+	return l.batchNormOp.SetTraining(isTraining)
+}
@@ -1,7 +1,10 @@
 package goras
 
 import (
+	"fmt"
+
 	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
 )
 
 // Conv2DLayer is a 2D convolutional layer.
@@ -9,11 +12,17 @@ import (
 //   - Output Shape: (batch_size, num_kernels, img_width, img_height)
 type Conv2DLayer struct {
 	LayerBase
-	Kernels    *G.Node
-	KernelSize []int
-	NumKernels int
-	Stride     []int
-	Padding    string
+	Kernels             *G.Node
+	Biases              *G.Node
+	KernelSize          []int
+	NumKernels          int
+	Stride              []int
+	Dilation            []int // The spacing between kernel positions, where [1, 1] is a normal convolution. Larger dilations give a wider receptive field with the same number of parameters.
+	Padding             string
+	UseBias             bool
+	Initializer         G.InitWFn
+	KernelRegularizer   Regularizer
+	ActivityRegularizer Regularizer
 }
 
 // SimpleConv2D is a constructor to create a 2D convolutional layer.
@@ -23,10 +32,16 @@ func SimpleConv2D(m *Model, name string, kernelSize int, numKernels int) *Conv2D
 	l := &Conv2DLayer{
 		LayerBase{m.Graph, name, "conv2d", true, nil, nil},
 		nil,
+		nil,
 		[]int{kernelSize, kernelSize},
 		numKernels,
 		[]int{1, 1},
+		[]int{1, 1},
 		"same",
+		true,
+		m.DefaultInitializer,
+		nil,
+		nil,
 	}
 	m.AddLayer(l)
 	return l
@@ -34,14 +49,21 @@ func SimpleConv2D(m *Model, name string, kernelSize int, numKernels int) *Conv2D
 
 // Conv2D is a constructor to create a 2D convolutional layer.
 // Options for padding are "same" or "valid".
+// It has biases, a dilation of [1, 1], and the kernels are initialised with the model's default initializer. These can be changed by setting UseBias, Dilation and Initializer before calling Attach.
 func Conv2D(m *Model, name string, kernelShape, stride []int, padding string, numKernels int) *Conv2DLayer {
 	l := &Conv2DLayer{
 		LayerBase{m.Graph, name, "conv2d", true, nil, nil},
 		nil,
+		nil,
 		kernelShape,
 		numKernels,
 		stride,
+		[]int{1, 1},
 		padding,
+		true,
+		m.DefaultInitializer,
+		nil,
+		nil,
 	}
 	m.AddLayer(l)
 	return l
@@ -52,19 +74,30 @@ func (l *Conv2DLayer) Attach(x *G.Node) (*G.Node, error) {
 	if err := validateShape(x.Shape(), valNDims(4)); err != nil {
 		return nil, err
 	}
-	pad := []int{0, 0} // padding=valid
-	if l.Padding == "same" {
-		pad = []int{l.KernelSize[0] / 2, l.KernelSize[1] / 2}
+	dilation := l.Dilation
+	if dilation == nil {
+		dilation = []int{1, 1}
+	}
+	pad, err := convPadding(l.Padding, l.KernelSize, dilation)
+	if err != nil {
+		return nil, err
 	}
 	previousKernels := x.Shape()[1]
-	l.Kernels = G.NewTensor(l.Graph, x.Dtype(), 4, G.WithShape(l.NumKernels, previousKernels, l.KernelSize[0], l.KernelSize[1]), G.WithInit(G.GlorotN(1.0)), G.WithName(l.Name()+".kernels"))
-	on, err := G.Conv2d(x, l.Kernels, l.KernelSize, pad, l.Stride, []int{1, 1})
-	l.OutputNode = on
-	if on != nil {
-		G.WithName(l.Name() + ".conv")(on)
+	l.Kernels = G.NewTensor(l.Graph, x.Dtype(), 4, G.WithShape(l.NumKernels, previousKernels, l.KernelSize[0], l.KernelSize[1]), G.WithInit(initializerOrDefault(l.Initializer)), G.WithName(l.Name()+".kernels"))
+	on, err := G.Conv2d(x, l.Kernels, l.KernelSize, pad, l.Stride, dilation)
+	if err != nil {
+		return nil, err
+	}
+	G.WithName(l.Name() + ".conv")(on)
+	if l.UseBias {
+		on, l.Biases, err = addChannelBiases(l.Name(), on)
+		if err != nil {
+			return nil, err
+		}
 	}
+	l.OutputNode = on
 	l.InputNodes = []*G.Node{x}
-	return on, err
+	return on, nil
 }
 
 // MustAttach attaches this layer to a previous node. It panics on error.
@@ -72,5 +105,253 @@ func (l *Conv2DLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
 
 // Parameters returns a map of the parameters of the layer.
 func (l *Conv2DLayer) Parameters() map[string]*G.Node {
-	return map[string]*G.Node{"kernels": l.Kernels}
+	return convParameters(l.Kernels, l.Biases, l.UseBias)
+}
+
+// RegularizationTerms returns the terms that the regularizers of the layer add to the loss.
+func (l *Conv2DLayer) RegularizationTerms() (map[string]*G.Node, error) {
+	return layerRegularizationTerms(l.KernelRegularizer, []*G.Node{l.Kernels}, l.ActivityRegularizer, l.OutputNode)
+}
+
+// Conv1DLayer is a 1D convolutional layer.
+//   - Input Shape: (batch_size, previous_kernels/previous_channels, length)
+//   - Output Shape: (batch_size, num_kernels, length)
+type Conv1DLayer struct {
+	LayerBase
+	Kernels             *G.Node
+	Biases              *G.Node
+	KernelSize          int
+	NumKernels          int
+	Stride              int
+	Padding             string
+	UseBias             bool
+	Initializer         G.InitWFn
+	KernelRegularizer   Regularizer
+	ActivityRegularizer Regularizer
+}
+
+// SimpleConv1D is a constructor to create a 1D convolutional layer.
+// It has a stride of 1, and padding of "same".
+// This means that the output will be the same length as the input.
+func SimpleConv1D(m *Model, name string, kernelSize int, numKernels int) *Conv1DLayer {
+	return Conv1D(m, name, kernelSize, 1, "same", numKernels)
+}
+
+// Conv1D is a constructor to create a 1D convolutional layer.
+// Options for padding are "same" or "valid".
+// It has biases, and the kernels are initialised with the model's default initializer. These can be changed by setting UseBias and Initializer before calling Attach.
+func Conv1D(m *Model, name string, kernelSize, stride int, padding string, numKernels int) *Conv1DLayer {
+	l := &Conv1DLayer{
+		LayerBase{m.Graph, name, "conv1d", true, nil, nil},
+		nil,
+		nil,
+		kernelSize,
+		numKernels,
+		stride,
+		padding,
+		true,
+		m.DefaultInitializer,
+		nil,
+		nil,
+	}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches this layer to a previous node.
+func (l *Conv1DLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(3)); err != nil {
+		return nil, err
+	}
+	on, kernels, err := attachConvND(l.Name(), x, []int{l.KernelSize}, []int{l.Stride}, []int{1}, l.Padding, l.NumKernels, 1, l.Initializer)
+	l.Kernels = kernels
+	if err != nil {
+		return nil, err
+	}
+	if l.UseBias {
+		on, l.Biases, err = addChannelBiases(l.Name(), on)
+		if err != nil {
+			return nil, err
+		}
+	}
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, nil
+}
+
+// MustAttach attaches this layer to a previous node. It panics on error.
+func (l *Conv1DLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *Conv1DLayer) Parameters() map[string]*G.Node {
+	return convParameters(l.Kernels, l.Biases, l.UseBias)
+}
+
+// RegularizationTerms returns the terms that the regularizers of the layer add to the loss.
+func (l *Conv1DLayer) RegularizationTerms() (map[string]*G.Node, error) {
+	return layerRegularizationTerms(l.KernelRegularizer, []*G.Node{l.Kernels}, l.ActivityRegularizer, l.OutputNode)
+}
+
+// Conv3DLayer is a 3D convolutional layer, for volumetric data.
+//   - Input Shape: (batch_size, previous_kernels/previous_channels, depth, height, width)
+//   - Output Shape: (batch_size, num_kernels, depth, height, width)
+type Conv3DLayer struct {
+	LayerBase
+	Kernels             *G.Node
+	Biases              *G.Node
+	KernelSize          []int
+	NumKernels          int
+	Stride              []int
+	Padding             string
+	UseBias             bool
+	Initializer         G.InitWFn
+	KernelRegularizer   Regularizer
+	ActivityRegularizer Regularizer
+}
+
+// SimpleConv3D is a constructor to create a 3D convolutional layer.
+// It has a kernel shape of [kernelSize, kernelSize, kernelSize], a stride of [1, 1, 1], and padding of "same".
+// This means that the output will be the same shape as the input.
+func SimpleConv3D(m *Model, name string, kernelSize int, numKernels int) *Conv3DLayer {
+	return Conv3D(m, name, []int{kernelSize, kernelSize, kernelSize}, []int{1, 1, 1}, "same", numKernels)
+}
+
+// Conv3D is a constructor to create a 3D convolutional layer.
+// Options for padding are "same" or "valid".
+// It has biases, and the kernels are initialised with the model's default initializer. These can be changed by setting UseBias and Initializer before calling Attach.
+func Conv3D(m *Model, name string, kernelShape, stride []int, padding string, numKernels int) *Conv3DLayer {
+	l := &Conv3DLayer{
+		LayerBase{m.Graph, name, "conv3d", true, nil, nil},
+		nil,
+		nil,
+		kernelShape,
+		numKernels,
+		stride,
+		padding,
+		true,
+		m.DefaultInitializer,
+		nil,
+		nil,
+	}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches this layer to a previous node.
+func (l *Conv3DLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(5)); err != nil {
+		return nil, err
+	}
+	on, kernels, err := attachConvND(l.Name(), x, l.KernelSize, l.Stride, filledInts(len(l.KernelSize), 1), l.Padding, l.NumKernels, 1, l.Initializer)
+	l.Kernels = kernels
+	if err != nil {
+		return nil, err
+	}
+	if l.UseBias {
+		on, l.Biases, err = addChannelBiases(l.Name(), on)
+		if err != nil {
+			return nil, err
+		}
+	}
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, nil
+}
+
+// MustAttach attaches this layer to a previous node. It panics on error.
+func (l *Conv3DLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *Conv3DLayer) Parameters() map[string]*G.Node {
+	return convParameters(l.Kernels, l.Biases, l.UseBias)
+}
+
+// RegularizationTerms returns the terms that the regularizers of the layer add to the loss.
+func (l *Conv3DLayer) RegularizationTerms() (map[string]*G.Node, error) {
+	return layerRegularizationTerms(l.KernelRegularizer, []*G.Node{l.Kernels}, l.ActivityRegularizer, l.OutputNode)
+}
+
+// attachConvND creates the kernels for a convolution with any number of spatial dims and applies it to x.
+// The kernels are called name+".kernels", and have shape (numKernels, in_channels/groups, ...kernelSize).
+func attachConvND(name string, x *G.Node, kernelSize, stride, dilation []int, padding string, numKernels, groups int, init G.InitWFn) (*G.Node, *G.Node, error) {
+	if len(kernelSize) != x.Dims()-2 || len(stride) != x.Dims()-2 || len(dilation) != x.Dims()-2 {
+		return nil, nil, fmt.Errorf("kernel size %v, stride %v and dilation %v must all have %v dims", kernelSize, stride, dilation, x.Dims()-2)
+	}
+	for i := range kernelSize {
+		if kernelSize[i] < 1 || stride[i] < 1 || dilation[i] < 1 {
+			return nil, nil, fmt.Errorf("kernel size, stride and dilation must be positive, got %v, %v and %v", kernelSize, stride, dilation)
+		}
+	}
+	if numKernels < 1 {
+		return nil, nil, fmt.Errorf("number of kernels must be positive, got %v", numKernels)
+	}
+	pad, err := convPadding(padding, kernelSize, dilation)
+	if err != nil {
+		return nil, nil, err
+	}
+	if groups < 1 || x.Shape()[1]%groups != 0 {
+		return nil, nil, fmt.Errorf("cannot split %v input channels into %v groups", x.Shape()[1], groups)
+	}
+	kernelShape := append(T.Shape{numKernels, x.Shape()[1] / groups}, kernelSize...)
+	kernels := G.NewTensor(x.Graph(), x.Dtype(), len(kernelShape), G.WithShape(kernelShape...), G.WithInit(initializerOrDefault(init)), G.WithName(name+".kernels"))
+	op, err := newConvNDOp(x.Shape(), kernelShape, stride, pad, dilation, groups)
+	if err != nil {
+		return nil, kernels, err
+	}
+	on, err := G.ApplyOp(op, x, kernels)
+	if err != nil {
+		return nil, kernels, err
+	}
+	G.WithName(name + ".conv")(on)
+	return on, kernels, nil
+}
+
+// convPadding works out how much padding to add to both sides of each spatial dim of a convolution.
+// Like Conv2D has always done, "same" padding adds half of the (dilated) kernel size to both sides, so the output is the same size as the input for odd kernel sizes and a stride of 1.
+func convPadding(padding string, kernelSize, dilation []int) ([]int, error) {
+	pad := make([]int, len(kernelSize)) // padding=valid
+	switch padding {
+	case "same":
+		for i := range pad {
+			pad[i] = (dilation[i]*(kernelSize[i]-1) + 1) / 2
+		}
+	case "valid":
+	default:
+		return nil, fmt.Errorf("padding must be either 'same' or 'valid' but got '%v'", padding)
+	}
+	return pad, nil
+}
+
+// addChannelBiases creates a bias for each channel (axis 1) of x, and adds it to every position of that channel.
+func addChannelBiases(name string, x *G.Node) (*G.Node, *G.Node, error) {
+	numChannels := x.Shape()[1]
+	biases := G.NewVector(x.Graph(), x.Dtype(), G.WithShape(numChannels), G.WithInit(G.Zeroes()), G.WithName(name+".biases"))
+	// gorgonia gets confused broadcasting a vector with only one element, and cannot broadcast tensors with more than 4 dims.
+	// So the biases are reshaped to (1, num_channels, 1) and x is reshaped to (batch_size, num_channels, everything else) for the add.
+	reshapedBiases, err := reshape(biases, T.Shape{1, numChannels, 1})
+	if err != nil {
+		return nil, nil, err
+	}
+	flatX, err := reshape(x, T.Shape{x.Shape()[0], numChannels, x.Shape()[2:].TotalSize()})
+	if err != nil {
+		return nil, nil, err
+	}
+	on, err := G.BroadcastAdd(flatX, reshapedBiases, nil, []byte{0, 2})
+	if err != nil {
+		return nil, nil, err
+	}
+	on, err = reshape(on, x.Shape())
+	if err != nil {
+		return nil, nil, err
+	}
+	G.WithName(name + ".bias_add")(on)
+	return on, biases, nil
+}
+
+// convParameters returns the parameters of a convolutional layer, which only has biases if useBias is true.
+func convParameters(kernels, biases *G.Node, useBias bool) map[string]*G.Node {
+	if !useBias {
+		return map[string]*G.Node{"kernels": kernels}
+	}
+	return map[string]*G.Node{"kernels": kernels, "biases": biases}
 }
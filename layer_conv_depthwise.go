@@ -0,0 +1,196 @@
+package goras
+
+import (
+	"fmt"
+
+	G "gorgonia.org/gorgonia"
+)
+
+// DepthwiseConv2DLayer is a 2D depthwise convolutional layer, where each input channel is convolved with its own kernels, instead of every kernel looking at every channel.
+// This uses far fewer parameters and calculations than a normal convolution.
+//   - Input Shape: (batch_size, previous_channels, img_width, img_height)
+//   - Output Shape: (batch_size, previous_channels * depth_multiplier, img_width, img_height)
+//
+// The output channels for input channel c are c*depth_multiplier to (c+1)*depth_multiplier - 1.
+type DepthwiseConv2DLayer struct {
+	LayerBase
+	Kernels             *G.Node // Shape (previous_channels * depth_multiplier, 1, kernel_width, kernel_height)
+	Biases              *G.Node
+	KernelSize          []int
+	DepthMultiplier     int // The number of kernels for each input channel
+	Stride              []int
+	Dilation            []int
+	Padding             string
+	UseBias             bool
+	Initializer         G.InitWFn
+	KernelRegularizer   Regularizer
+	ActivityRegularizer Regularizer
+}
+
+// SimpleDepthwiseConv2D is a constructor to create a 2D depthwise convolutional layer.
+// It has a kernel shape of [kernelSize, kernelSize], a depth multiplier of 1, a stride of [1, 1], and padding of "same".
+// This means that the output will be the same shape as the input.
+func SimpleDepthwiseConv2D(m *Model, name string, kernelSize int) *DepthwiseConv2DLayer {
+	return DepthwiseConv2D(m, name, []int{kernelSize, kernelSize}, []int{1, 1}, "same", 1)
+}
+
+// DepthwiseConv2D is a constructor to create a 2D depthwise convolutional layer.
+// Options for padding are "same" or "valid".
+// It has biases, a dilation of [1, 1], and the kernels are initialised with the model's default initializer. These can be changed by setting UseBias, Dilation and Initializer before calling Attach.
+func DepthwiseConv2D(m *Model, name string, kernelShape, stride []int, padding string, depthMultiplier int) *DepthwiseConv2DLayer {
+	l := &DepthwiseConv2DLayer{
+		LayerBase{m.Graph, name, "depthwiseconv2d", true, nil, nil},
+		nil,
+		nil,
+		kernelShape,
+		depthMultiplier,
+		stride,
+		[]int{1, 1},
+		padding,
+		true,
+		m.DefaultInitializer,
+		nil,
+		nil,
+	}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches this layer to a previous node.
+func (l *DepthwiseConv2DLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(4)); err != nil {
+		return nil, err
+	}
+	if l.DepthMultiplier < 1 {
+		return nil, fmt.Errorf("depth multiplier must be positive, got %v", l.DepthMultiplier)
+	}
+	numChannels := x.Shape()[1]
+	on, kernels, err := attachConvND(l.Name(), x, l.KernelSize, l.Stride, l.Dilation, l.Padding, numChannels*l.DepthMultiplier, numChannels, l.Initializer)
+	l.Kernels = kernels
+	if err != nil {
+		return nil, err
+	}
+	if l.UseBias {
+		on, l.Biases, err = addChannelBiases(l.Name(), on)
+		if err != nil {
+			return nil, err
+		}
+	}
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, nil
+}
+
+// MustAttach attaches this layer to a previous node. It panics on error.
+func (l *DepthwiseConv2DLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *DepthwiseConv2DLayer) Parameters() map[string]*G.Node {
+	return convParameters(l.Kernels, l.Biases, l.UseBias)
+}
+
+// RegularizationTerms returns the terms that the regularizers of the layer add to the loss.
+func (l *DepthwiseConv2DLayer) RegularizationTerms() (map[string]*G.Node, error) {
+	return layerRegularizationTerms(l.KernelRegularizer, []*G.Node{l.Kernels}, l.ActivityRegularizer, l.OutputNode)
+}
+
+// SeparableConv2DLayer is a 2D depthwise separable convolutional layer.
+// It is a depthwise convolution, followed by a 1x1 (pointwise) convolution that mixes the channels.
+// This gives a similar result to a normal convolution with far fewer parameters, so it is popular for small and fast models.
+//   - Input Shape: (batch_size, previous_channels, img_width, img_height)
+//   - Output Shape: (batch_size, num_kernels, img_width, img_height)
+type SeparableConv2DLayer struct {
+	LayerBase
+	DepthwiseKernels    *G.Node // Shape (previous_channels * depth_multiplier, 1, kernel_width, kernel_height)
+	PointwiseKernels    *G.Node // Shape (num_kernels, previous_channels * depth_multiplier, 1, 1)
+	Biases              *G.Node
+	KernelSize          []int
+	NumKernels          int
+	DepthMultiplier     int // The number of depthwise kernels for each input channel
+	Stride              []int
+	Dilation            []int
+	Padding             string
+	UseBias             bool
+	Initializer         G.InitWFn   // Used for both the depthwise and pointwise kernels
+	KernelRegularizer   Regularizer // Used for both the depthwise and pointwise kernels
+	ActivityRegularizer Regularizer
+}
+
+// SimpleSeparableConv2D is a constructor to create a 2D depthwise separable convolutional layer.
+// It has a kernel shape of [kernelSize, kernelSize], a depth multiplier of 1, a stride of [1, 1], and padding of "same".
+// This means that the output will be the same shape as the input.
+func SimpleSeparableConv2D(m *Model, name string, kernelSize int, numKernels int) *SeparableConv2DLayer {
+	return SeparableConv2D(m, name, []int{kernelSize, kernelSize}, []int{1, 1}, "same", numKernels)
+}
+
+// SeparableConv2D is a constructor to create a 2D depthwise separable convolutional layer.
+// Options for padding are "same" or "valid".
+// It has biases, a depth multiplier of 1, a dilation of [1, 1], and the kernels are initialised with the model's default initializer.
+// These can be changed by setting UseBias, DepthMultiplier, Dilation and Initializer before calling Attach.
+func SeparableConv2D(m *Model, name string, kernelShape, stride []int, padding string, numKernels int) *SeparableConv2DLayer {
+	l := &SeparableConv2DLayer{
+		LayerBase{m.Graph, name, "separableconv2d", true, nil, nil},
+		nil,
+		nil,
+		nil,
+		kernelShape,
+		numKernels,
+		1,
+		stride,
+		[]int{1, 1},
+		padding,
+		true,
+		m.DefaultInitializer,
+		nil,
+		nil,
+	}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches this layer to a previous node.
+func (l *SeparableConv2DLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(4)); err != nil {
+		return nil, err
+	}
+	if l.DepthMultiplier < 1 {
+		return nil, fmt.Errorf("depth multiplier must be positive, got %v", l.DepthMultiplier)
+	}
+	numChannels := x.Shape()[1]
+	on, kernels, err := attachConvND(l.Name()+".depthwise", x, l.KernelSize, l.Stride, l.Dilation, l.Padding, numChannels*l.DepthMultiplier, numChannels, l.Initializer)
+	l.DepthwiseKernels = kernels
+	if err != nil {
+		return nil, err
+	}
+	on, kernels, err = attachConvND(l.Name()+".pointwise", on, []int{1, 1}, []int{1, 1}, []int{1, 1}, "valid", l.NumKernels, 1, l.Initializer)
+	l.PointwiseKernels = kernels
+	if err != nil {
+		return nil, err
+	}
+	if l.UseBias {
+		on, l.Biases, err = addChannelBiases(l.Name(), on)
+		if err != nil {
+			return nil, err
+		}
+	}
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, nil
+}
+
+// MustAttach attaches this layer to a previous node. It panics on error.
+func (l *SeparableConv2DLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *SeparableConv2DLayer) Parameters() map[string]*G.Node {
+	params := map[string]*G.Node{"depthwise_kernels": l.DepthwiseKernels, "pointwise_kernels": l.PointwiseKernels}
+	if l.UseBias {
+		params["biases"] = l.Biases
+	}
+	return params
+}
+
+// RegularizationTerms returns the terms that the regularizers of the layer add to the loss.
+func (l *SeparableConv2DLayer) RegularizationTerms() (map[string]*G.Node, error) {
+	return layerRegularizationTerms(l.KernelRegularizer, []*G.Node{l.DepthwiseKernels, l.PointwiseKernels}, l.ActivityRegularizer, l.OutputNode)
+}
@@ -0,0 +1,104 @@
+package goras
+
+import (
+	"fmt"
+
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// Conv2DTransposeLayer is a 2D transposed convolutional layer (sometimes called a deconvolution layer).
+// It is roughly the opposite of a Conv2DLayer, so it is useful for upsampling in things like autoencoders.
+//   - Input Shape: (batch_size, previous_kernels/previous_channels, img_height, img_width)
+//   - Output Shape: (batch_size, num_kernels, img_height*stride, img_width*stride) [with padding "same", "valid" will be larger if the kernel is bigger than the stride]
+type Conv2DTransposeLayer struct {
+	LayerBase
+	Kernels             *G.Node
+	Biases              *G.Node
+	KernelSize          []int
+	NumKernels          int
+	Stride              []int
+	Padding             string
+	UseBias             bool
+	Initializer         G.InitWFn
+	KernelRegularizer   Regularizer
+	ActivityRegularizer Regularizer
+}
+
+// Conv2DTranspose is a constructor to create a 2D transposed convolutional layer.
+// Options for padding are "same" or "valid".
+// It has biases, and the kernels are initialised with the model's default initializer. These can be changed by setting UseBias and Initializer before calling Attach.
+func Conv2DTranspose(m *Model, name string, kernelShape, stride []int, padding string, numKernels int) *Conv2DTransposeLayer {
+	l := &Conv2DTransposeLayer{
+		LayerBase{m.Graph, name, "conv2dtranspose", true, nil, nil},
+		nil,
+		nil,
+		kernelShape,
+		numKernels,
+		stride,
+		padding,
+		true,
+		m.DefaultInitializer,
+		nil,
+		nil,
+	}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches this layer to a previous node.
+func (l *Conv2DTransposeLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(4)); err != nil {
+		return nil, err
+	}
+	if len(l.KernelSize) != 2 || len(l.Stride) != 2 {
+		return nil, fmt.Errorf("kernel size and stride must both have 2 elements, got %v and %v", l.KernelSize, l.Stride)
+	}
+	if l.Padding != "same" && l.Padding != "valid" {
+		return nil, fmt.Errorf("padding must be either 'same' or 'valid', got '%v'", l.Padding)
+	}
+	outShape := T.Shape{x.Shape()[0], l.NumKernels, 0, 0}
+	padBefore := []int{0, 0}
+	for i := 0; i < 2; i++ {
+		if l.KernelSize[i] < 1 || l.Stride[i] < 1 {
+			return nil, fmt.Errorf("kernel size and stride must be positive, got %v and %v", l.KernelSize, l.Stride)
+		}
+		inSize := x.Shape()[i+2]
+		outShape[i+2] = inSize * l.Stride[i]
+		overhang := max(l.KernelSize[i]-l.Stride[i], 0)
+		if l.Padding == "same" {
+			padBefore[i] = overhang / 2
+		} else {
+			outShape[i+2] += overhang
+		}
+	}
+	previousKernels := x.Shape()[1]
+	l.Kernels = G.NewTensor(l.Graph, x.Dtype(), 4, G.WithShape(previousKernels, l.NumKernels, l.KernelSize[0], l.KernelSize[1]), G.WithInit(inputsFirstInit(initializerOrDefault(l.Initializer))), G.WithName(l.Name()+".kernels"))
+	on, err := G.ApplyOp(&conv2DTransposeOp{l.Stride, padBefore, outShape}, x, l.Kernels)
+	if err != nil {
+		return nil, err
+	}
+	G.WithName(l.Name() + ".convtranspose")(on)
+	if l.UseBias {
+		on, l.Biases, err = addChannelBiases(l.Name(), on)
+		if err != nil {
+			return nil, err
+		}
+	}
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, nil
+}
+
+// MustAttach attaches this layer to a previous node. It panics on error.
+func (l *Conv2DTransposeLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *Conv2DTransposeLayer) Parameters() map[string]*G.Node {
+	return convParameters(l.Kernels, l.Biases, l.UseBias)
+}
+
+// RegularizationTerms returns the terms that the regularizers of the layer add to the loss.
+func (l *Conv2DTransposeLayer) RegularizationTerms() (map[string]*G.Node, error) {
+	return layerRegularizationTerms(l.KernelRegularizer, []*G.Node{l.Kernels}, l.ActivityRegularizer, l.OutputNode)
+}
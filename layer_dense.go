@@ -1,6 +1,8 @@
 package goras
 
 import (
+	"fmt"
+
 	G "gorgonia.org/gorgonia"
 	T "gorgonia.org/tensor"
 )
@@ -11,13 +13,19 @@ import (
 //   - Output Shape: (batch_size, num_nodes)
 type DenseLayer struct {
 	LayerBase
-	Weights *G.Node
-	Nodes   int
+	Weights             *G.Node
+	Biases              *G.Node
+	Nodes               int
+	UseBias             bool
+	Initializer         G.InitWFn
+	KernelRegularizer   Regularizer
+	ActivityRegularizer Regularizer
 }
 
 // Dense creates a new dense layer on the specified model.
+// It has biases, and the weights are initialised with the model's default initializer. These can be changed by setting UseBias and Initializer before calling Attach.
 func Dense(m *Model, name string, nodes int) *DenseLayer {
-	d := &DenseLayer{LayerBase{m.Graph, name, "dense", true, nil, nil}, nil, nodes}
+	d := &DenseLayer{LayerBase{m.Graph, name, "dense", true, nil, nil}, nil, nil, nodes, true, m.DefaultInitializer, nil, nil}
 	m.AddLayer(d)
 	return d
 }
@@ -28,24 +36,22 @@ func (l *DenseLayer) Attach(n *G.Node) (*G.Node, error) {
 		return nil, err
 	}
 	numInputs := n.Shape()[1]
-	batchSize := n.Shape()[0]
-	l.Weights = G.NewMatrix(l.Graph, n.Dtype(), G.WithShape(numInputs+1, l.Nodes), G.WithInit(G.GlorotN(1.0)), G.WithName(l.Name()+".weights"))
-	bias := G.NewConstant(T.Ones(n.Dtype(), batchSize, 1), G.WithName(l.Name()+".bias"))
-	// Build the graph
-	withBias, err := G.Concat(1, n, bias)
-	if err != nil {
-		return nil, err
+	l.Weights = G.NewMatrix(l.Graph, n.Dtype(), G.WithShape(numInputs, l.Nodes), G.WithInit(initializerOrDefault(l.Initializer)), G.WithName(l.Name()+".weights"))
+	var on *G.Node
+	var err error
+	if l.UseBias {
+		l.Biases = G.NewVector(l.Graph, n.Dtype(), G.WithShape(l.Nodes), G.WithInit(G.Zeroes()), G.WithName(l.Name()+".biases"))
+		on, err = affine(n, l.Weights, l.Biases)
+	} else {
+		on, err = G.Mul(n, l.Weights)
 	}
-	multiplied, err := G.Mul(withBias, l.Weights)
 	if err != nil {
 		return nil, err
 	}
-	l.OutputNode = multiplied
-	if l.OutputNode != nil {
-		G.WithName(l.Name() + ".matmul")(l.OutputNode)
-	}
+	G.WithName(l.Name() + ".matmul")(on)
+	l.OutputNode = on
 	l.InputNodes = []*G.Node{n}
-	return multiplied, nil
+	return on, nil
 }
 
 // MustAttach attaches the layer to a previous node, panicking on error.
@@ -53,5 +59,42 @@ func (l *DenseLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
 
 // Parameters returns a map of the parameters of the layer.
 func (l *DenseLayer) Parameters() map[string]*G.Node {
-	return map[string]*G.Node{"weights": l.Weights}
+	if !l.UseBias {
+		return map[string]*G.Node{"weights": l.Weights}
+	}
+	return map[string]*G.Node{"weights": l.Weights, "biases": l.Biases}
+}
+
+// RegularizationTerms returns the terms that the regularizers of the layer add to the loss.
+func (l *DenseLayer) RegularizationTerms() (map[string]*G.Node, error) {
+	return layerRegularizationTerms(l.KernelRegularizer, []*G.Node{l.Weights}, l.ActivityRegularizer, l.OutputNode)
+}
+
+// upgradeParams splits the weights of old checkpoints, where the biases were stored as the last row of the weights, into separate weights and biases.
+func (l *DenseLayer) upgradeParams(params map[string]*T.Dense) error {
+	weights, ok := params[l.Name()+":weights"]
+	if !ok || l.Weights == nil {
+		return nil
+	}
+	if _, ok := params[l.Name()+":biases"]; ok {
+		return nil
+	}
+	numInputs := l.Weights.Shape()[0]
+	if !exactShapeEq(weights.Shape(), T.Shape{numInputs + 1, l.Nodes}) {
+		return nil
+	}
+	if !l.UseBias {
+		return fmt.Errorf("parameter %v has biases in it, but the layer does not use biases", l.Name()+":weights")
+	}
+	newWeights, err := weights.Slice(G.S(0, numInputs))
+	if err != nil {
+		return err
+	}
+	newBiases, err := weights.Slice(G.S(numInputs))
+	if err != nil {
+		return err
+	}
+	params[l.Name()+":weights"] = newWeights.Materialize().(*T.Dense)
+	params[l.Name()+":biases"] = newBiases.Materialize().(*T.Dense)
+	return nil
 }
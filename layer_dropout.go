@@ -5,25 +5,30 @@ import (
 )
 
 // DropoutLayer is a dropout layer.
+// While training, it randomly sets values to zero with the given probability, and scales the rest up to keep the same mean.
+// While predicting, it does nothing.
 //   - Input/Output Shape: any shape
 type DropoutLayer struct {
 	LayerBase
 	DropoutProbability float64
+	dropoutOp          *dropoutOp
 }
 
 // Dropout creates a new DropoutLayer on the Model with the given dropout probability.
 func Dropout(m *Model, name string, dropoutProbability float64) *DropoutLayer {
-	d := &DropoutLayer{LayerBase{m.Graph, name, "dropout", false, nil, nil}, dropoutProbability}
+	d := &DropoutLayer{LayerBase{m.Graph, name, "dropout", false, nil, nil}, dropoutProbability, nil}
 	m.AddLayer(d)
 	return d
 }
 
 // Attach attaches the DropoutLayer to the given node.
 func (l *DropoutLayer) Attach(n *G.Node) (*G.Node, error) {
-	on, err := G.Dropout(n, l.DropoutProbability)
+	l.dropoutOp = newDropoutOp(l.Name(), l.DropoutProbability)
+	on, err := G.ApplyOp(l.dropoutOp, n)
 	l.OutputNode = on
 	if on != nil {
 		G.WithName(l.Name() + ".dropout")(on)
+		passMask(n, on)
 	}
 	l.InputNodes = []*G.Node{n}
 	return on, err
@@ -34,3 +39,11 @@ func (l *DropoutLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
 
 // Parameters returns a map of the parameters of the layer.
 func (d *DropoutLayer) Parameters() map[string]*G.Node { return make(map[string]*G.Node) }
+
+// SetTraining sets whether the dropout is active.
+func (l *DropoutLayer) SetTraining(isTraining bool) error {
+	if l.dropoutOp == nil {
+		return nil
+	}
+	return l.dropoutOp.SetTraining(isTraining)
+}
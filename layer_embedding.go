@@ -0,0 +1,93 @@
+package goras
+
+import (
+	"fmt"
+
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// EmbeddingLayer is a layer that maps integer tokens to learned vectors.
+// The input should be a tensor of integers, each of which is in the range [0, vocab_size).
+//   - Input Shape: (batch_size,) or (batch_size, sequence_length)
+//   - Output Shape: (batch_size, embedding_dim) or (batch_size, sequence_length, embedding_dim)
+type EmbeddingLayer struct {
+	LayerBase
+	Embeddings   *G.Node
+	VocabSize    int
+	EmbeddingDim int
+	// PaddingIndex is the token that is used for padding. It always maps to a vector of zeros and is never trained. -1 means there is no padding token.
+	PaddingIndex        int
+	DType               T.Dtype
+	Initializer         G.InitWFn
+	KernelRegularizer   Regularizer
+	ActivityRegularizer Regularizer
+}
+
+// Embedding creates a new embedding layer on the specified model.
+// It has no padding index, uses float64, and uses the model's default initializer. These can be changed by setting the fields before calling Attach.
+func Embedding(m *Model, name string, vocabSize, embeddingDim int) *EmbeddingLayer {
+	if vocabSize < 1 || embeddingDim < 1 {
+		panic("vocabSize and embeddingDim must be greater than 0")
+	}
+	l := &EmbeddingLayer{
+		LayerBase:    LayerBase{m.Graph, name, "embedding", true, nil, nil},
+		VocabSize:    vocabSize,
+		EmbeddingDim: embeddingDim,
+		PaddingIndex: -1,
+		DType:        T.Float64,
+		Initializer:  m.DefaultInitializer,
+	}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the layer to a previous node.
+func (l *EmbeddingLayer) Attach(n *G.Node) (*G.Node, error) {
+	if err := validateShape(n.Shape(), valAtLeastNDims(1), valAtMostNDims(2)); err != nil {
+		return nil, err
+	}
+	if n.Dtype() != G.Int {
+		return nil, fmt.Errorf("EmbeddingLayer only supports integer inputs")
+	}
+	if l.PaddingIndex >= l.VocabSize {
+		return nil, fmt.Errorf("padding index %v is out of range for vocab size %v", l.PaddingIndex, l.VocabSize)
+	}
+	l.Embeddings = G.NewMatrix(l.Graph, l.DType, G.WithShape(l.VocabSize, l.EmbeddingDim), G.WithInit(l.initEmbeddings), G.WithName(l.Name()+".embeddings"))
+	on, err := G.ApplyOp(&embeddingOp{name: l.Name(), indexDims: n.Dims(), paddingIndex: l.PaddingIndex}, l.Embeddings, n)
+	if err != nil {
+		return nil, err
+	}
+	G.WithName(l.Name() + ".embedding")(on)
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{n}
+	return on, nil
+}
+
+// initEmbeddings initialises the table with the layer's initializer, but with the padding row set to zero.
+func (l *EmbeddingLayer) initEmbeddings(dt T.Dtype, s ...int) interface{} {
+	vals := initializerOrDefault(l.Initializer)(dt, s...)
+	if l.PaddingIndex < 0 {
+		return vals
+	}
+	switch vals := vals.(type) {
+	case []float64:
+		clear(vals[l.PaddingIndex*l.EmbeddingDim : (l.PaddingIndex+1)*l.EmbeddingDim])
+	case []float32:
+		clear(vals[l.PaddingIndex*l.EmbeddingDim : (l.PaddingIndex+1)*l.EmbeddingDim])
+	}
+	return vals
+}
+
+// MustAttach attaches the layer to a previous node. It panics on error.
+func (l *EmbeddingLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *EmbeddingLayer) Parameters() map[string]*G.Node {
+	return map[string]*G.Node{"embeddings": l.Embeddings}
+}
+
+// RegularizationTerms returns the terms that the regularizers of the layer add to the loss.
+func (l *EmbeddingLayer) RegularizationTerms() (map[string]*G.Node, error) {
+	return layerRegularizationTerms(l.KernelRegularizer, []*G.Node{l.Embeddings}, l.ActivityRegularizer, l.OutputNode)
+}
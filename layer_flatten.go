@@ -0,0 +1,44 @@
+package goras
+
+import (
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// FlattenLayer flattens every dim apart from the batch dim, so the output shape does not need to be worked out by hand.
+//   - Input Shape: (batch_size, ...)
+//   - Output Shape: (batch_size, product of all the other dims)
+type FlattenLayer struct {
+	LayerBase
+}
+
+// Flatten creates a new FlattenLayer on the Model.
+func Flatten(model *Model, name string) *FlattenLayer {
+	l := &FlattenLayer{
+		LayerBase: LayerBase{model.Graph, name, "flatten", false, nil, nil},
+	}
+	model.AddLayer(l)
+	return l
+}
+
+// Attach attaches the FlattenLayer to the given node.
+func (l *FlattenLayer) Attach(n *G.Node) (*G.Node, error) {
+	if err := validateShape(n.Shape(), valAtLeastNDims(2)); err != nil {
+		return nil, err
+	}
+	on, err := reshape(n, T.Shape{n.Shape()[0], n.Shape()[1:].TotalSize()})
+	l.OutputNode = on
+	if on != nil {
+		G.WithName(l.Name() + ".flatten")(on)
+	}
+	l.InputNodes = []*G.Node{n}
+	return on, err
+}
+
+// MustAttach attaches the FlattenLayer to the given node. It panics on error.
+func (l *FlattenLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *FlattenLayer) Parameters() map[string]*G.Node {
+	return make(map[string]*G.Node)
+}
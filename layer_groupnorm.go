@@ -0,0 +1,88 @@
+package goras
+
+import (
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// GroupNormLayer is a group normalisation layer. The channels of each sample are split into NumGroups groups, and each group is normalised separately.
+// Unlike batch norm, this behaves the same while training and predicting.
+//   - Input/Output Shape: (batch_size, num_channels, img_height, img_width)
+type GroupNormLayer struct {
+	LayerBase
+	Gamma     *G.Node
+	Beta      *G.Node
+	NumGroups int // If this is 0, there will be one group per channel (instance norm)
+	Epsilon   float64
+	// GammaInitializer and BetaInitializer are Ones and Zeros by default.
+	GammaInitializer G.InitWFn
+	BetaInitializer  G.InitWFn
+}
+
+// GroupNorm creates a new group normalisation layer on the specified model.
+// The number of channels of the input must be divisible by numGroups.
+func GroupNorm(m *Model, name string, numGroups int) *GroupNormLayer {
+	if numGroups < 1 {
+		panic("numGroups must be greater than 0")
+	}
+	l := &GroupNormLayer{LayerBase{m.Graph, name, "groupnorm", true, nil, nil}, nil, nil, numGroups, 0.001, Ones(), Zeros()}
+	m.AddLayer(l)
+	return l
+}
+
+// InstanceNorm creates a new instance normalisation layer on the specified model.
+// This is a group norm layer with one group per channel, so each channel of each sample is normalised separately.
+func InstanceNorm(m *Model, name string) *GroupNormLayer {
+	l := &GroupNormLayer{LayerBase{m.Graph, name, "instancenorm", true, nil, nil}, nil, nil, 0, 0.001, Ones(), Zeros()}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the layer to a previous node.
+func (l *GroupNormLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(4)); err != nil {
+		return nil, err
+	}
+	batchSize, channels := x.Shape()[0], x.Shape()[1]
+	numGroups := l.NumGroups
+	if numGroups == 0 {
+		numGroups = channels
+	}
+	if err := validateShape(x.Shape(), valNthDimDivisibleBy(1, numGroups)); err != nil {
+		return nil, err
+	}
+	l.Gamma = G.NewVector(l.Graph, x.Dtype(), G.WithShape(channels), G.WithInit(l.GammaInitializer), G.WithName(l.Name()+".gamma"))
+	l.Beta = G.NewVector(l.Graph, x.Dtype(), G.WithShape(channels), G.WithInit(l.BetaInitializer), G.WithName(l.Name()+".beta"))
+	rows, err := reshape(x, T.Shape{batchSize * numGroups, x.Shape().TotalSize() / (batchSize * numGroups)})
+	if err != nil {
+		return nil, err
+	}
+	normed, err := normaliseRows(rows, l.Epsilon, l.Name())
+	if err != nil {
+		return nil, err
+	}
+	normed, err = reshape(normed, x.Shape().Clone())
+	if err != nil {
+		return nil, err
+	}
+	normed, err = G.BroadcastHadamardProd(normed, l.Gamma, nil, []byte{0, 2, 3})
+	if err != nil {
+		return nil, err
+	}
+	on, err := G.BroadcastAdd(normed, l.Beta, nil, []byte{0, 2, 3})
+	if err != nil {
+		return nil, err
+	}
+	G.WithName(l.Name() + ".groupnorm")(on)
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, nil
+}
+
+// MustAttach attaches the layer to a previous node. It panics on error.
+func (l *GroupNormLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *GroupNormLayer) Parameters() map[string]*G.Node {
+	return map[string]*G.Node{"gamma": l.Gamma, "beta": l.Beta}
+}
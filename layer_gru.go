@@ -0,0 +1,165 @@
+package goras
+
+import (
+	G "gorgonia.org/gorgonia"
+)
+
+// GRULayer is a gated recurrent unit layer.
+// The weights of the three gates are stored side by side in the order update, reset, candidate (the same as Keras).
+// Timesteps that are masked (see Masking) are skipped, and are zero in the output sequence.
+//   - Input Shape: (batch_size, timesteps, num_features)
+//   - Output Shape: (batch_size, units) or (batch_size, timesteps, units) if ReturnSequences is true
+//   - Initial States (optional): [(batch_size, units)]
+type GRULayer struct {
+	LayerBase
+	Kernel              *G.Node
+	RecurrentKernel     *G.Node
+	Bias                *G.Node
+	Units               int
+	ReturnSequences     bool
+	Initializer         G.InitWFn   // Used for both the kernel and the recurrent kernel
+	KernelRegularizer   Regularizer // Used for both the kernel and the recurrent kernel
+	ActivityRegularizer Regularizer
+}
+
+// GRU creates a new GRU layer on the specified model.
+// It returns only the final output, and the weights are initialised with the model's default initializer. These can be changed by setting the fields before calling Attach.
+func GRU(m *Model, name string, units int) *GRULayer {
+	l := &GRULayer{
+		LayerBase:   LayerBase{m.Graph, name, "gru", true, nil, nil},
+		Units:       units,
+		Initializer: m.DefaultInitializer,
+	}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the layer to a previous node. The initial state is all zeros.
+func (l *GRULayer) Attach(x *G.Node) (*G.Node, error) {
+	return l.AttachWithInitialStates(x)
+}
+
+// AttachWithInitialStates attaches the layer to a previous node, using the given node as the initial state.
+func (l *GRULayer) AttachWithInitialStates(x *G.Node, initialStates ...*G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(3)); err != nil {
+		return nil, err
+	}
+	states, err := recurrentStates(l.Name(), x, l.Units, 1, initialStates)
+	if err != nil {
+		return nil, err
+	}
+	numFeatures := x.Shape()[2]
+	l.Kernel = G.NewMatrix(l.Graph, x.Dtype(), G.WithShape(numFeatures, 3*l.Units), G.WithInit(initializerOrDefault(l.Initializer)), G.WithName(l.Name()+".kernel"))
+	l.RecurrentKernel = G.NewMatrix(l.Graph, x.Dtype(), G.WithShape(l.Units, 3*l.Units), G.WithInit(initializerOrDefault(l.Initializer)), G.WithName(l.Name()+".recurrent_kernel"))
+	l.Bias = G.NewVector(l.Graph, x.Dtype(), G.WithShape(3*l.Units), G.WithInit(G.Zeroes()), G.WithName(l.Name()+".bias"))
+	mask := sequenceMask(x)
+	on, err := unrollRecurrent(x, states, mask, l.ReturnSequences, l.step)
+	if err != nil {
+		return nil, err
+	}
+	G.WithName(l.Name() + ".gru")(on)
+	if l.ReturnSequences && mask != nil {
+		SetMask(on, mask)
+	}
+	l.OutputNode = on
+	l.InputNodes = append([]*G.Node{x}, initialStates...)
+	return on, nil
+}
+
+func (l *GRULayer) step(xt *G.Node, states []*G.Node) ([]*G.Node, error) {
+	h := states[0]
+	xw, err := affine(xt, l.Kernel, l.Bias)
+	if err != nil {
+		return nil, err
+	}
+	// The update and reset gates use the first two thirds of the recurrent kernel
+	recurrentGates, err := sliceAxis(l.RecurrentKernel, 1, 0, 2*l.Units)
+	if err != nil {
+		return nil, err
+	}
+	recurrentCandidate, err := sliceGate(l.RecurrentKernel, 2, l.Units)
+	if err != nil {
+		return nil, err
+	}
+	hu, err := G.Mul(h, recurrentGates)
+	if err != nil {
+		return nil, err
+	}
+	gates := make([]*G.Node, 2)
+	for i := range gates {
+		xg, err := sliceGate(xw, i, l.Units)
+		if err != nil {
+			return nil, err
+		}
+		hg, err := sliceGate(hu, i, l.Units)
+		if err != nil {
+			return nil, err
+		}
+		sum, err := G.Add(xg, hg)
+		if err != nil {
+			return nil, err
+		}
+		gates[i], err = G.Sigmoid(sum)
+		if err != nil {
+			return nil, err
+		}
+	}
+	updateGate, resetGate := gates[0], gates[1]
+	// candidate = tanh(x*kernel_c + (reset*h)*recurrent_kernel_c + bias_c)
+	xc, err := sliceGate(xw, 2, l.Units)
+	if err != nil {
+		return nil, err
+	}
+	resetH, err := G.HadamardProd(resetGate, h)
+	if err != nil {
+		return nil, err
+	}
+	hc, err := G.Mul(resetH, recurrentCandidate)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := G.Add(xc, hc)
+	if err != nil {
+		return nil, err
+	}
+	candidate, err := G.Tanh(sum)
+	if err != nil {
+		return nil, err
+	}
+	// h = update*h + (1-update)*candidate, which is written as candidate + update*(h-candidate) to avoid needing a constant
+	diff, err := G.Sub(h, candidate)
+	if err != nil {
+		return nil, err
+	}
+	scaled, err := G.HadamardProd(updateGate, diff)
+	if err != nil {
+		return nil, err
+	}
+	h, err = G.Add(candidate, scaled)
+	if err != nil {
+		return nil, err
+	}
+	return []*G.Node{h}, nil
+}
+
+// MustAttach attaches the layer to a previous node. It panics on error.
+func (l *GRULayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// MustAttachWithInitialStates attaches the layer to a previous node, using the given node as the initial state. It panics on error.
+func (l *GRULayer) MustAttachWithInitialStates(n *G.Node, initialStates ...*G.Node) *G.Node {
+	on, err := l.AttachWithInitialStates(n, initialStates...)
+	if err != nil {
+		panic(err)
+	}
+	return on
+}
+
+// Parameters returns a map of the parameters of the layer.
+func (l *GRULayer) Parameters() map[string]*G.Node {
+	return map[string]*G.Node{"kernel": l.Kernel, "recurrent_kernel": l.RecurrentKernel, "bias": l.Bias}
+}
+
+// RegularizationTerms returns the terms that the regularizers of the layer add to the loss.
+func (l *GRULayer) RegularizationTerms() (map[string]*G.Node, error) {
+	return layerRegularizationTerms(l.KernelRegularizer, []*G.Node{l.Kernel, l.RecurrentKernel}, l.ActivityRegularizer, l.OutputNode)
+}
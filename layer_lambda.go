@@ -0,0 +1,112 @@
+package goras
+
+import (
+	"fmt"
+
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// LambdaLayer is a layer that applies any function of gorgonia nodes to its inputs.
+// This is useful for one-off transforms (scaling, slicing, custom maths) that do not have their own layer, so that they still show up in the model summary.
+//   - Input Shape: any number of inputs of any shape, as long as the function can handle them
+//   - Output Shape: whatever the function returns
+//
+// Any constants created by the function must be given names that are unique in the graph, for example by starting them with the name of the layer.
+type LambdaLayer struct {
+	LayerBase
+	Fn          func(...*G.Node) (*G.Node, error)
+	Params      map[string]*G.Node
+	Initializer G.InitWFn // Used by AddParameter when it is not given an initializer
+}
+
+// Lambda creates a new LambdaLayer on the Model, which applies fn to its inputs when it is attached.
+// If the function needs its own trainable parameters, they can be created with AddParameter before the layer is attached.
+func Lambda(m *Model, name string, fn func(...*G.Node) (*G.Node, error)) *LambdaLayer {
+	l := &LambdaLayer{LayerBase{m.Graph, name, "lambda", true, nil, nil}, fn, make(map[string]*G.Node), m.DefaultInitializer}
+	m.AddLayer(l)
+	return l
+}
+
+// AddParameter creates a new trainable parameter for the layer, with the given dtype, shape and initializer.
+// If init is nil, the Initializer of the layer is used, which is the model's default initializer unless it has been changed.
+// The returned node can then be used by the function of the layer, for example:
+//
+//	var scale *G.Node
+//	l := Lambda(model, "scale", func(xs ...*G.Node) (*G.Node, error) { return G.HadamardProd(xs[0], scale) })
+//	scale = l.MustAddParameter("scale", T.Float64, []int{1, 4}, Ones())
+//
+// The parameter is saved and loaded with the rest of the model, and is trained unless the layer is made untrainable.
+func (l *LambdaLayer) AddParameter(name string, dtype T.Dtype, shape []int, init G.InitWFn) (*G.Node, error) {
+	if _, ok := l.Params[name]; ok {
+		return nil, fmt.Errorf("lambda layer %s already has a parameter called %s", l.Name(), name)
+	}
+	if len(shape) == 0 {
+		return nil, fmt.Errorf("lambda layer %s parameter %s must have at least one dimension", l.Name(), name)
+	}
+	if init == nil {
+		init = initializerOrDefault(l.Initializer)
+	}
+	p := G.NewTensor(l.Graph, dtype, len(shape), G.WithShape(shape...), G.WithInit(init), G.WithName(l.Name()+"."+name))
+	l.Params[name] = p
+	return p, nil
+}
+
+// MustAddParameter calls AddParameter, but panics if there is an error.
+func (l *LambdaLayer) MustAddParameter(name string, dtype T.Dtype, shape []int, init G.InitWFn) *G.Node {
+	p, err := l.AddParameter(name, dtype, shape, init)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
+
+// Attach attaches the layer to the previous nodes, by calling the function of the layer on them.
+func (l *LambdaLayer) Attach(ns ...*G.Node) (*G.Node, error) {
+	if l.Fn == nil {
+		return nil, fmt.Errorf("lambda layer %s has no function", l.Name())
+	}
+	if len(ns) == 0 {
+		return nil, fmt.Errorf("lambda layer %s needs at least one input", l.Name())
+	}
+	on, err := l.Fn(ns...)
+	if err != nil {
+		return nil, err
+	}
+	if on == nil {
+		return nil, fmt.Errorf("lambda layer %s function returned a nil node", l.Name())
+	}
+	// Only name the output if the function made a new node, otherwise we would be renaming one of the inputs or parameters
+	if !l.isExistingNode(on, ns) {
+		G.WithName(l.Name() + ".lambda")(on)
+	}
+	l.OutputNode = on
+	l.InputNodes = ns
+	return on, nil
+}
+
+// MustAttach attaches the layer to the previous nodes, panicking on error.
+func (l *LambdaLayer) MustAttach(ns ...*G.Node) *G.Node { return mustAttachMulti(l, ns...) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *LambdaLayer) Parameters() map[string]*G.Node {
+	params := make(map[string]*G.Node, len(l.Params))
+	for name, p := range l.Params {
+		params[name] = p
+	}
+	return params
+}
+
+func (l *LambdaLayer) isExistingNode(n *G.Node, inputs []*G.Node) bool {
+	for _, i := range inputs {
+		if n == i {
+			return true
+		}
+	}
+	for _, p := range l.Params {
+		if n == p {
+			return true
+		}
+	}
+	return false
+}
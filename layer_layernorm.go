@@ -0,0 +1,131 @@
+package goras
+
+import (
+	"fmt"
+
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// LayerNormLayer is a layer normalisation layer. Each sample is normalised over its trailing NumAxes axes.
+// Unlike batch norm, this behaves the same while training and predicting.
+//   - Input/Output Shape: (batch_size, ...other_dims)
+type LayerNormLayer struct {
+	LayerBase
+	Gamma   *G.Node
+	Beta    *G.Node
+	NumAxes int
+	Epsilon float64
+	// GammaInitializer and BetaInitializer are Ones and Zeros by default.
+	GammaInitializer G.InitWFn
+	BetaInitializer  G.InitWFn
+}
+
+// LayerNorm creates a new layer normalisation layer on the specified model.
+// It normalises over the last axis, with an epsilon of 0.001. These can be changed by setting the fields before calling Attach.
+func LayerNorm(m *Model, name string) *LayerNormLayer {
+	l := &LayerNormLayer{
+		LayerBase:        LayerBase{m.Graph, name, "layernorm", true, nil, nil},
+		NumAxes:          1,
+		Epsilon:          0.001,
+		GammaInitializer: Ones(),
+		BetaInitializer:  Zeros(),
+	}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the layer to a previous node.
+func (l *LayerNormLayer) Attach(x *G.Node) (*G.Node, error) {
+	if l.NumAxes < 1 {
+		return nil, fmt.Errorf("layernorm must normalise over at least one axis, got %v", l.NumAxes)
+	}
+	if err := validateShape(x.Shape(), valAtLeastNDims(l.NumAxes+1)); err != nil {
+		return nil, err
+	}
+	featureShape := x.Shape()[x.Dims()-l.NumAxes:]
+	numFeatures := featureShape.TotalSize()
+	numRows := x.Shape().TotalSize() / numFeatures
+	l.Gamma = G.NewTensor(l.Graph, x.Dtype(), l.NumAxes, G.WithShape(featureShape...), G.WithInit(l.GammaInitializer), G.WithName(l.Name()+".gamma"))
+	l.Beta = G.NewTensor(l.Graph, x.Dtype(), l.NumAxes, G.WithShape(featureShape...), G.WithInit(l.BetaInitializer), G.WithName(l.Name()+".beta"))
+	rows, err := reshape(x, T.Shape{numRows, numFeatures})
+	if err != nil {
+		return nil, err
+	}
+	normed, err := normaliseRows(rows, l.Epsilon, l.Name())
+	if err != nil {
+		return nil, err
+	}
+	gamma, err := reshape(l.Gamma, T.Shape{numFeatures})
+	if err != nil {
+		return nil, err
+	}
+	beta, err := reshape(l.Beta, T.Shape{numFeatures})
+	if err != nil {
+		return nil, err
+	}
+	normed, err = G.BroadcastHadamardProd(normed, gamma, nil, []byte{0})
+	if err != nil {
+		return nil, err
+	}
+	normed, err = G.BroadcastAdd(normed, beta, nil, []byte{0})
+	if err != nil {
+		return nil, err
+	}
+	on, err := reshape(normed, x.Shape().Clone())
+	if err != nil {
+		return nil, err
+	}
+	G.WithName(l.Name() + ".layernorm")(on)
+	// Each timestep is normalised on its own unless the timesteps axis is one of the normalised axes
+	if l.NumAxes <= x.Dims()-2 {
+		passMask(x, on)
+	}
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, nil
+}
+
+// MustAttach attaches the layer to a previous node. It panics on error.
+func (l *LayerNormLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *LayerNormLayer) Parameters() map[string]*G.Node {
+	return map[string]*G.Node{"gamma": l.Gamma, "beta": l.Beta}
+}
+
+// normaliseRows normalises each row of the matrix x to have a mean of 0 and a variance of 1.
+// This is shared by the per-sample normalisation layers, which reshape their inputs so that each group to normalise is one row.
+func normaliseRows(x *G.Node, epsilon float64, name string) (*G.Node, error) {
+	mean, err := G.Mean(x, 1)
+	if err != nil {
+		return nil, err
+	}
+	centered, err := G.BroadcastSub(x, mean, nil, []byte{1})
+	if err != nil {
+		return nil, err
+	}
+	variance, err := G.Square(centered)
+	if err != nil {
+		return nil, err
+	}
+	variance, err = G.Mean(variance, 1)
+	if err != nil {
+		return nil, err
+	}
+	eps := G.NewConstant(castVal(x.Dtype(), epsilon), G.WithName(name+".epsilon"))
+	variance, err = G.Add(variance, eps)
+	if err != nil {
+		return nil, err
+	}
+	// G.InverseSqrt has an incorrect gradient, so we do this in two steps
+	std, err := G.Sqrt(variance)
+	if err != nil {
+		return nil, err
+	}
+	invStd, err := G.Inverse(std)
+	if err != nil {
+		return nil, err
+	}
+	return G.BroadcastHadamardProd(centered, invStd, nil, []byte{1})
+}
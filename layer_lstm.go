@@ -0,0 +1,160 @@
+package goras
+
+import (
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// LSTMLayer is a long short-term memory layer.
+// The weights of the four gates are stored side by side in the order input, forget, cell, output (the same as Keras).
+// Timesteps that are masked (see Masking) are skipped, and are zero in the output sequence.
+//   - Input Shape: (batch_size, timesteps, num_features)
+//   - Output Shape: (batch_size, units) or (batch_size, timesteps, units) if ReturnSequences is true
+//   - Initial States (optional): [hidden (batch_size, units), cell (batch_size, units)]
+type LSTMLayer struct {
+	LayerBase
+	Kernel              *G.Node
+	RecurrentKernel     *G.Node
+	Bias                *G.Node
+	Units               int
+	ReturnSequences     bool
+	Initializer         G.InitWFn   // Used for both the kernel and the recurrent kernel
+	KernelRegularizer   Regularizer // Used for both the kernel and the recurrent kernel
+	ActivityRegularizer Regularizer
+}
+
+// LSTM creates a new LSTM layer on the specified model.
+// It returns only the final output, and the weights are initialised with the model's default initializer. These can be changed by setting the fields before calling Attach.
+func LSTM(m *Model, name string, units int) *LSTMLayer {
+	l := &LSTMLayer{
+		LayerBase:   LayerBase{m.Graph, name, "lstm", true, nil, nil},
+		Units:       units,
+		Initializer: m.DefaultInitializer,
+	}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the layer to a previous node. The initial states are all zeros.
+func (l *LSTMLayer) Attach(x *G.Node) (*G.Node, error) {
+	return l.AttachWithInitialStates(x)
+}
+
+// AttachWithInitialStates attaches the layer to a previous node, using the given nodes as the initial hidden and cell states.
+func (l *LSTMLayer) AttachWithInitialStates(x *G.Node, initialStates ...*G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(3)); err != nil {
+		return nil, err
+	}
+	states, err := recurrentStates(l.Name(), x, l.Units, 2, initialStates)
+	if err != nil {
+		return nil, err
+	}
+	numFeatures := x.Shape()[2]
+	l.Kernel = G.NewMatrix(l.Graph, x.Dtype(), G.WithShape(numFeatures, 4*l.Units), G.WithInit(initializerOrDefault(l.Initializer)), G.WithName(l.Name()+".kernel"))
+	l.RecurrentKernel = G.NewMatrix(l.Graph, x.Dtype(), G.WithShape(l.Units, 4*l.Units), G.WithInit(initializerOrDefault(l.Initializer)), G.WithName(l.Name()+".recurrent_kernel"))
+	l.Bias = G.NewVector(l.Graph, x.Dtype(), G.WithShape(4*l.Units), G.WithInit(l.initBias), G.WithName(l.Name()+".bias"))
+	mask := sequenceMask(x)
+	on, err := unrollRecurrent(x, states, mask, l.ReturnSequences, l.step)
+	if err != nil {
+		return nil, err
+	}
+	G.WithName(l.Name() + ".lstm")(on)
+	if l.ReturnSequences && mask != nil {
+		SetMask(on, mask)
+	}
+	l.OutputNode = on
+	l.InputNodes = append([]*G.Node{x}, initialStates...)
+	return on, nil
+}
+
+// initBias sets the forget gate bias to one and all other biases to zero, which helps the layer remember things at the start of training.
+func (l *LSTMLayer) initBias(dt T.Dtype, s ...int) interface{} {
+	vals := G.Zeroes()(dt, s...)
+	for i := l.Units; i < 2*l.Units; i++ {
+		switch vals := vals.(type) {
+		case []float64:
+			vals[i] = 1
+		case []float32:
+			vals[i] = 1
+		}
+	}
+	return vals
+}
+
+func (l *LSTMLayer) step(xt *G.Node, states []*G.Node) ([]*G.Node, error) {
+	h, c := states[0], states[1]
+	xw, err := affine(xt, l.Kernel, l.Bias)
+	if err != nil {
+		return nil, err
+	}
+	hu, err := G.Mul(h, l.RecurrentKernel)
+	if err != nil {
+		return nil, err
+	}
+	z, err := G.Add(xw, hu)
+	if err != nil {
+		return nil, err
+	}
+	gates := make([]*G.Node, 4)
+	for i := range gates {
+		gate, err := sliceGate(z, i, l.Units)
+		if err != nil {
+			return nil, err
+		}
+		if i == 2 {
+			gate, err = G.Tanh(gate)
+		} else {
+			gate, err = G.Sigmoid(gate)
+		}
+		if err != nil {
+			return nil, err
+		}
+		gates[i] = gate
+	}
+	inputGate, forgetGate, candidate, outputGate := gates[0], gates[1], gates[2], gates[3]
+	// c = forget*c + input*candidate
+	kept, err := G.HadamardProd(forgetGate, c)
+	if err != nil {
+		return nil, err
+	}
+	added, err := G.HadamardProd(inputGate, candidate)
+	if err != nil {
+		return nil, err
+	}
+	c, err = G.Add(kept, added)
+	if err != nil {
+		return nil, err
+	}
+	// h = output*tanh(c)
+	tanhC, err := G.Tanh(c)
+	if err != nil {
+		return nil, err
+	}
+	h, err = G.HadamardProd(outputGate, tanhC)
+	if err != nil {
+		return nil, err
+	}
+	return []*G.Node{h, c}, nil
+}
+
+// MustAttach attaches the layer to a previous node. It panics on error.
+func (l *LSTMLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// MustAttachWithInitialStates attaches the layer to a previous node, using the given nodes as the initial hidden and cell states. It panics on error.
+func (l *LSTMLayer) MustAttachWithInitialStates(n *G.Node, initialStates ...*G.Node) *G.Node {
+	on, err := l.AttachWithInitialStates(n, initialStates...)
+	if err != nil {
+		panic(err)
+	}
+	return on
+}
+
+// Parameters returns a map of the parameters of the layer.
+func (l *LSTMLayer) Parameters() map[string]*G.Node {
+	return map[string]*G.Node{"kernel": l.Kernel, "recurrent_kernel": l.RecurrentKernel, "bias": l.Bias}
+}
+
+// RegularizationTerms returns the terms that the regularizers of the layer add to the loss.
+func (l *LSTMLayer) RegularizationTerms() (map[string]*G.Node, error) {
+	return layerRegularizationTerms(l.KernelRegularizer, []*G.Node{l.Kernel, l.RecurrentKernel}, l.ActivityRegularizer, l.OutputNode)
+}
@@ -0,0 +1,60 @@
+package goras
+
+import (
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// MaskingLayer is a layer that marks padded timesteps of a sequence, so that later layers can ignore them.
+// A timestep is padding if all of its features are equal to MaskValue. Padded timesteps are set to zero in the output.
+// The mask is passed along by layers that keep the timesteps the same, and is used by recurrent layers, attention, GlobalAveragePooling1D, GlobalMaxPooling1D, MSELoss and CCELoss.
+//   - Input/Output Shape: (batch_size, timesteps, ...features)
+type MaskingLayer struct {
+	LayerBase
+	MaskValue float64
+}
+
+// Masking creates a new MaskingLayer on the Model, which masks timesteps where every feature is maskValue.
+func Masking(m *Model, name string, maskValue float64) *MaskingLayer {
+	l := &MaskingLayer{LayerBase{m.Graph, name, "masking", false, nil, nil}, maskValue}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the MaskingLayer to the given node.
+func (l *MaskingLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valAtLeastNDims(2)); err != nil {
+		return nil, err
+	}
+	maskValue := G.NewConstant(castVal(x.Dtype(), l.MaskValue), G.WithName(l.Name()+".mask_value"))
+	// A timestep is kept if any of its features are not the mask value
+	mask, err := G.Ne(x, maskValue, true)
+	if err != nil {
+		return nil, err
+	}
+	if x.Dims() > 2 {
+		mask, err = reshape(mask, T.Shape{x.Shape()[0], x.Shape()[1], x.Shape()[2:].TotalSize()})
+		if err != nil {
+			return nil, err
+		}
+		if mask, err = G.Max(mask, 2); err != nil {
+			return nil, err
+		}
+	}
+	G.WithName(l.Name() + ".mask")(mask)
+	on, err := multiplyByMask(x, mask)
+	if err != nil {
+		return nil, err
+	}
+	G.WithName(l.Name() + ".masking")(on)
+	SetMask(on, mask)
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, nil
+}
+
+// MustAttach attaches the MaskingLayer to the given node. It panics on error.
+func (l *MaskingLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *MaskingLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
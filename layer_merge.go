@@ -0,0 +1,154 @@
+package goras
+
+import (
+	"fmt"
+
+	G "gorgonia.org/gorgonia"
+)
+
+// MergeLayer is a layer that combines multiple nodes of the same shape elementwise.
+//   - Input Shape: any shape [all inputs must have the same shape]
+//   - Output Shape: the same shape as the inputs
+type MergeLayer struct {
+	LayerBase
+	Operation string
+}
+
+// Merge creates a new MergeLayer on the Model with the given operation.
+// The operation can be one of ["add", "subtract", "multiply", "average"].
+// Subtract requires exactly two inputs, and returns the first minus the second.
+func Merge(m *Model, name string, operation string) *MergeLayer {
+	l := &MergeLayer{LayerBase{m.Graph, name, "merge(" + operation + ")", false, nil, nil}, operation}
+	m.AddLayer(l)
+	return l
+}
+
+// Add creates a new MergeLayer on the Model that adds its inputs together.
+func Add(m *Model, name string) *MergeLayer {
+	return Merge(m, name, "add")
+}
+
+// Subtract creates a new MergeLayer on the Model that subtracts its second input from its first.
+func Subtract(m *Model, name string) *MergeLayer {
+	return Merge(m, name, "subtract")
+}
+
+// Multiply creates a new MergeLayer on the Model that multiplies its inputs together elementwise.
+func Multiply(m *Model, name string) *MergeLayer {
+	return Merge(m, name, "multiply")
+}
+
+// Average creates a new MergeLayer on the Model that averages its inputs.
+func Average(m *Model, name string) *MergeLayer {
+	return Merge(m, name, "average")
+}
+
+// Attach attaches this layer to some previous nodes.
+func (l *MergeLayer) Attach(ns ...*G.Node) (*G.Node, error) {
+	if len(ns) < 2 {
+		return nil, fmt.Errorf("merge layers need at least 2 inputs but got %v", len(ns))
+	}
+	for i, n := range ns[1:] {
+		if err := validateShape(n.Shape(), valMatchingDim(ns[0].Shape())); err != nil {
+			return nil, fmt.Errorf("input %v does not match input 0: %v", i+1, err)
+		}
+	}
+	var on *G.Node
+	var err error
+	switch l.Operation {
+	case "add", "average":
+		on, err = foldNodes(G.Add, ns)
+		if err == nil && l.Operation == "average" {
+			scale := G.NewConstant(castVal(ns[0].Dtype(), 1/float64(len(ns))), G.WithName(l.Name()+".scale"))
+			on, err = G.Mul(on, scale)
+		}
+	case "multiply":
+		on, err = foldNodes(G.HadamardProd, ns)
+	case "subtract":
+		if len(ns) != 2 {
+			return nil, fmt.Errorf("subtract needs exactly 2 inputs but got %v", len(ns))
+		}
+		on, err = G.Sub(ns[0], ns[1])
+	default:
+		return nil, fmt.Errorf("invalid merge operation: %s", l.Operation)
+	}
+	if err != nil {
+		return nil, err
+	}
+	G.WithName(l.Name() + "." + l.Operation)(on)
+	if err := mergeMasks(on, ns...); err != nil {
+		return nil, err
+	}
+	l.OutputNode = on
+	l.InputNodes = ns
+	return on, nil
+}
+
+// foldNodes combines all of the nodes, from left to right, using the function f.
+func foldNodes(f func(a, b *G.Node) (*G.Node, error), ns []*G.Node) (*G.Node, error) {
+	on := ns[0]
+	for _, n := range ns[1:] {
+		var err error
+		on, err = f(on, n)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return on, nil
+}
+
+// MustAttach attaches this layer to some previous nodes. It panics on error.
+func (l *MergeLayer) MustAttach(ns ...*G.Node) *G.Node { return mustAttachMulti(l, ns...) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *MergeLayer) Parameters() map[string]*G.Node { return make(map[string]*G.Node) }
+
+// ConcatenateLayer is a layer that joins multiple nodes together along an axis.
+//   - Input Shape: any shape [all inputs must have the same shape, apart from along the axis]
+//   - Output Shape: the same shape as the inputs, with the axis being the sum of the axis of all inputs
+type ConcatenateLayer struct {
+	LayerBase
+	Axis int
+}
+
+// Concatenate creates a new ConcatenateLayer on the Model that joins its inputs along the given axis.
+// Axis 0 is the batch axis, so you will usually want to use 1 or more.
+func Concatenate(m *Model, name string, axis int) *ConcatenateLayer {
+	l := &ConcatenateLayer{LayerBase{m.Graph, name, "concatenate", false, nil, nil}, axis}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches this layer to some previous nodes.
+func (l *ConcatenateLayer) Attach(ns ...*G.Node) (*G.Node, error) {
+	if len(ns) < 2 {
+		return nil, fmt.Errorf("concatenate needs at least 2 inputs but got %v", len(ns))
+	}
+	if l.Axis < 0 || l.Axis >= ns[0].Dims() {
+		return nil, fmt.Errorf("cannot concatenate along axis %v of inputs with %v dims", l.Axis, ns[0].Dims())
+	}
+	for i, n := range ns[1:] {
+		// All axes apart from the concatenation axis must match
+		expected := ns[0].Shape().Clone()
+		if n.Dims() == len(expected) {
+			expected[l.Axis] = n.Shape()[l.Axis]
+		}
+		if err := validateShape(n.Shape(), valMatchingDim(expected)); err != nil {
+			return nil, fmt.Errorf("input %v does not match input 0 along the non-concatenated axes: %v", i+1, err)
+		}
+	}
+	on, err := G.Concat(l.Axis, ns...)
+	if err != nil {
+		return nil, err
+	}
+	G.WithName(l.Name() + ".concat")(on)
+	l.OutputNode = on
+	l.InputNodes = ns
+	return on, nil
+}
+
+// MustAttach attaches this layer to some previous nodes. It panics on error.
+func (l *ConcatenateLayer) MustAttach(ns ...*G.Node) *G.Node { return mustAttachMulti(l, ns...) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *ConcatenateLayer) Parameters() map[string]*G.Node { return make(map[string]*G.Node) }
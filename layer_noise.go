@@ -0,0 +1,131 @@
+package goras
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// GaussianNoiseLayer is a layer that adds gaussian noise with a mean of 0 to its input while training.
+// While predicting, it does nothing. This is a simple way to make a model more robust.
+//   - Input/Output Shape: any shape
+type GaussianNoiseLayer struct {
+	LayerBase
+	Stddev    float64
+	Seed      int64 // The seed for the noise. If it is 0 (the default), the current time is used.
+	augmentOp *augmentOp
+}
+
+// GaussianNoise creates a new GaussianNoiseLayer on the Model, which adds noise with the given standard deviation.
+// The seed can be set with the Seed field before calling Attach.
+func GaussianNoise(m *Model, name string, stddev float64) *GaussianNoiseLayer {
+	l := &GaussianNoiseLayer{LayerBase{m.Graph, name, "gaussiannoise", false, nil, nil}, stddev, 0, nil}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the GaussianNoiseLayer to the given node.
+func (l *GaussianNoiseLayer) Attach(x *G.Node) (*G.Node, error) {
+	if l.Stddev < 0 {
+		return nil, fmt.Errorf("gaussian noise stddev cannot be negative, got %v", l.Stddev)
+	}
+	stddev := l.Stddev
+	on, op, err := attachAugmentation(l.Name(), x, x.Shape(), l.Seed, func(rng *rand.Rand, inShape T.Shape, training bool) augmentation {
+		if !training || stddev == 0 {
+			return augmentation{}
+		}
+		noise := make([]float64, inShape.TotalSize())
+		for i := range noise {
+			noise[i] = rng.NormFloat64() * stddev
+		}
+		return augmentation{noise: noise}
+	})
+	l.augmentOp = op
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, err
+}
+
+// MustAttach attaches the GaussianNoiseLayer to the given node. It panics on error.
+func (l *GaussianNoiseLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *GaussianNoiseLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
+
+// SetTraining sets whether the noise is active.
+func (l *GaussianNoiseLayer) SetTraining(isTraining bool) error {
+	if l.augmentOp == nil {
+		return nil
+	}
+	return l.augmentOp.SetTraining(isTraining)
+}
+
+// GaussianDropoutLayer is a layer that multiplies its input by gaussian noise with a mean of 1 while training.
+// The noise has a standard deviation of sqrt(rate / (1 - rate)), which is the same as the standard deviation of normal dropout with that rate.
+// While predicting, it does nothing.
+//   - Input/Output Shape: any shape
+type GaussianDropoutLayer struct {
+	LayerBase
+	Rate      float64
+	Seed      int64 // The seed for the noise. If it is 0 (the default), the current time is used.
+	augmentOp *augmentOp
+}
+
+// GaussianDropout creates a new GaussianDropoutLayer on the Model with the given dropout rate, which must be in [0, 1).
+// The seed can be set with the Seed field before calling Attach.
+func GaussianDropout(m *Model, name string, rate float64) *GaussianDropoutLayer {
+	l := &GaussianDropoutLayer{LayerBase{m.Graph, name, "gaussiandropout", false, nil, nil}, rate, 0, nil}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the GaussianDropoutLayer to the given node.
+func (l *GaussianDropoutLayer) Attach(x *G.Node) (*G.Node, error) {
+	if l.Rate < 0 || l.Rate >= 1 {
+		return nil, fmt.Errorf("gaussian dropout rate must be in [0, 1), got %v", l.Rate)
+	}
+	stddev := math.Sqrt(l.Rate / (1 - l.Rate))
+	on, op, err := attachAugmentation(l.Name(), x, x.Shape(), l.Seed, func(rng *rand.Rand, inShape T.Shape, training bool) augmentation {
+		if !training || stddev == 0 {
+			return augmentation{}
+		}
+		scale := make([]float64, inShape.TotalSize())
+		for i := range scale {
+			scale[i] = 1 + rng.NormFloat64()*stddev
+		}
+		return augmentation{scale: scale}
+	})
+	l.augmentOp = op
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, err
+}
+
+// MustAttach attaches the GaussianDropoutLayer to the given node. It panics on error.
+func (l *GaussianDropoutLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *GaussianDropoutLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
+
+// SetTraining sets whether the noise is active.
+func (l *GaussianDropoutLayer) SetTraining(isTraining bool) error {
+	if l.augmentOp == nil {
+		return nil
+	}
+	return l.augmentOp.SetTraining(isTraining)
+}
+
+// attachAugmentation applies a new augmentOp to x, and names the output after the layer.
+func attachAugmentation(name string, x *G.Node, outShape T.Shape, seed int64, sample augmentSampler) (*G.Node, *augmentOp, error) {
+	op := newAugmentOp(name, x.Shape(), outShape, seed, sample)
+	on, err := G.ApplyOp(op, x)
+	if err != nil {
+		return nil, nil, err
+	}
+	G.WithName(name + ".augment")(on)
+	passMask(x, on)
+	return on, op, nil
+}
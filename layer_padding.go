@@ -0,0 +1,118 @@
+package goras
+
+import (
+	"fmt"
+
+	G "gorgonia.org/gorgonia"
+)
+
+// ZeroPadding2DLayer is a layer that adds rows and columns of zeros to the sides of images.
+//   - Input Shape: (batch_size, num_channels, img_height, img_width)
+//   - Output Shape: (batch_size, num_channels, img_height+top+bottom, img_width+left+right)
+type ZeroPadding2DLayer struct {
+	LayerBase
+	Padding []int // [top, bottom, left, right]
+}
+
+// ZeroPadding2D creates a new zero padding layer on the specified model.
+// The padding can be given as one number for all sides, two numbers for [top and bottom, left and right], or four numbers for [top, bottom, left, right].
+func ZeroPadding2D(m *Model, name string, padding ...int) *ZeroPadding2DLayer {
+	l := &ZeroPadding2DLayer{LayerBase{m.Graph, name, "zeropadding2d", false, nil, nil}, padding}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the ZeroPadding2DLayer to the given node.
+func (l *ZeroPadding2DLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(4)); err != nil {
+		return nil, err
+	}
+	pad, err := expandSides2D(l.Padding)
+	if err != nil {
+		return nil, err
+	}
+	op, err := newPad2DOp(x.Shape(), pad)
+	if err != nil {
+		return nil, err
+	}
+	on, err := G.ApplyOp(op, x)
+	l.OutputNode = on
+	if on != nil {
+		G.WithName(l.Name() + ".pad")(on)
+	}
+	l.InputNodes = []*G.Node{x}
+	return on, err
+}
+
+// MustAttach attaches the ZeroPadding2DLayer to the given node. It panics on error.
+func (l *ZeroPadding2DLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *ZeroPadding2DLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
+
+// Cropping2DLayer is a layer that removes rows and columns from the sides of images.
+// It is useful for making the outputs of an encoder and decoder line up for skip connections.
+//   - Input Shape: (batch_size, num_channels, img_height, img_width)
+//   - Output Shape: (batch_size, num_channels, img_height-top-bottom, img_width-left-right)
+type Cropping2DLayer struct {
+	LayerBase
+	Cropping []int // [top, bottom, left, right]
+}
+
+// Cropping2D creates a new cropping layer on the specified model.
+// The cropping can be given as one number for all sides, two numbers for [top and bottom, left and right], or four numbers for [top, bottom, left, right].
+// It is an error to crop away the whole image.
+func Cropping2D(m *Model, name string, cropping ...int) *Cropping2DLayer {
+	l := &Cropping2DLayer{LayerBase{m.Graph, name, "cropping2d", false, nil, nil}, cropping}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the Cropping2DLayer to the given node.
+func (l *Cropping2DLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(4)); err != nil {
+		return nil, err
+	}
+	crop, err := expandSides2D(l.Cropping)
+	if err != nil {
+		return nil, err
+	}
+	op, err := newPad2DOp(x.Shape(), negateInts(crop))
+	if err != nil {
+		return nil, err
+	}
+	on, err := G.ApplyOp(op, x)
+	l.OutputNode = on
+	if on != nil {
+		G.WithName(l.Name() + ".crop")(on)
+	}
+	l.InputNodes = []*G.Node{x}
+	return on, err
+}
+
+// MustAttach attaches the Cropping2DLayer to the given node. It panics on error.
+func (l *Cropping2DLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *Cropping2DLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
+
+// expandSides2D converts one, two or four amounts into [top, bottom, left, right].
+func expandSides2D(amounts []int) ([]int, error) {
+	var sides []int
+	switch len(amounts) {
+	case 1:
+		sides = []int{amounts[0], amounts[0], amounts[0], amounts[0]}
+	case 2:
+		sides = []int{amounts[0], amounts[0], amounts[1], amounts[1]}
+	case 4:
+		sides = append([]int{}, amounts...)
+	default:
+		return nil, fmt.Errorf("expected 1, 2 or 4 amounts for the sides but got %v", amounts)
+	}
+	for _, s := range sides {
+		if s < 0 {
+			return nil, fmt.Errorf("amounts for the sides cannot be negative, got %v", amounts)
+		}
+	}
+	return sides, nil
+}
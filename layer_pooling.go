@@ -1,6 +1,7 @@
 package goras
 
 import (
+	"fmt"
 	"math"
 
 	G "gorgonia.org/gorgonia"
@@ -69,6 +70,388 @@ func (l *MaxPooling2DLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l,
 // Parameters returns a map of the parameters of the layer.
 func (l *MaxPooling2DLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
 
+// AveragePooling2DLayer is an average pooling layer.
+// With "same" padding, the padded positions are not counted in the average.
+//   - Input Shape: (batch_size, num_channels, img_height, img_width)
+//   - Output Shape: (batch_size, num_channels, img_height, img_width) [img_height and img_width will be smaller than the input]
+type AveragePooling2DLayer struct {
+	LayerBase
+	PoolSize []int
+	Stride   []int
+	Padding  string
+}
+
+// SimpleAveragePooling2D creates a new average pooling layer on the specified model.
+// It will have padding=same stride=poolSize, and it is the same in both dims.
+func SimpleAveragePooling2D(m *Model, name string, poolSize int) *AveragePooling2DLayer {
+	size := []int{poolSize, poolSize}
+	return AveragePooling2D(m, name, size, size, "same")
+}
+
+// AveragePooling2D creates a new average pooling layer on the specified model.
+// Padding can be either "same" or "valid".
+func AveragePooling2D(m *Model, name string, poolSize, stride []int, padding string) *AveragePooling2DLayer {
+	l := &AveragePooling2DLayer{
+		LayerBase{m.Graph, name, "avgpool2d", false, nil, nil},
+		poolSize,
+		stride,
+		padding,
+	}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the AveragePooling2DLayer to the given node.
+func (l *AveragePooling2DLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(4)); err != nil {
+		return nil, err
+	}
+	on, err := attachPoolND(l.Name(), x, true, l.PoolSize, l.Stride, l.Padding)
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, err
+}
+
+// MustAttach attaches the AveragePooling2DLayer to the given node.
+func (l *AveragePooling2DLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *AveragePooling2DLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
+
+// GlobalAveragePooling2DLayer takes the average of each channel over the whole image.
+// This can be used instead of a reshape before the dense layers of a classifier, and it does not depend on the image size.
+//   - Input Shape: (batch_size, num_channels, img_height, img_width)
+//   - Output Shape: (batch_size, num_channels)
+type GlobalAveragePooling2DLayer struct {
+	LayerBase
+}
+
+// GlobalAveragePooling2D creates a new global average pooling layer on the specified model.
+func GlobalAveragePooling2D(m *Model, name string) *GlobalAveragePooling2DLayer {
+	l := &GlobalAveragePooling2DLayer{LayerBase{m.Graph, name, "globalavgpool2d", false, nil, nil}}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the GlobalAveragePooling2DLayer to the given node.
+func (l *GlobalAveragePooling2DLayer) Attach(x *G.Node) (*G.Node, error) {
+	on, err := attachGlobalPool2D(l.Name(), x, true)
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, err
+}
+
+// MustAttach attaches the GlobalAveragePooling2DLayer to the given node.
+func (l *GlobalAveragePooling2DLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *GlobalAveragePooling2DLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
+
+// GlobalMaxPooling2DLayer takes the max of each channel over the whole image.
+// This can be used instead of a reshape before the dense layers of a classifier, and it does not depend on the image size.
+//   - Input Shape: (batch_size, num_channels, img_height, img_width)
+//   - Output Shape: (batch_size, num_channels)
+type GlobalMaxPooling2DLayer struct {
+	LayerBase
+}
+
+// GlobalMaxPooling2D creates a new global max pooling layer on the specified model.
+func GlobalMaxPooling2D(m *Model, name string) *GlobalMaxPooling2DLayer {
+	l := &GlobalMaxPooling2DLayer{LayerBase{m.Graph, name, "globalmaxpool2d", false, nil, nil}}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the GlobalMaxPooling2DLayer to the given node.
+func (l *GlobalMaxPooling2DLayer) Attach(x *G.Node) (*G.Node, error) {
+	on, err := attachGlobalPool2D(l.Name(), x, false)
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, err
+}
+
+// MustAttach attaches the GlobalMaxPooling2DLayer to the given node.
+func (l *GlobalMaxPooling2DLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *GlobalMaxPooling2DLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
+
+// GlobalAveragePooling1DLayer takes the average of each feature over every timestep of a sequence.
+// Timesteps that are masked (see Masking) are not included in the average.
+//   - Input Shape: (batch_size, timesteps, num_features)
+//   - Output Shape: (batch_size, num_features)
+//
+// Note that this pools over axis 1, like the recurrent layers, rather than the last axis like MaxPooling1D.
+type GlobalAveragePooling1DLayer struct {
+	LayerBase
+}
+
+// GlobalAveragePooling1D creates a new global average pooling layer for sequences on the specified model.
+func GlobalAveragePooling1D(m *Model, name string) *GlobalAveragePooling1DLayer {
+	l := &GlobalAveragePooling1DLayer{LayerBase{m.Graph, name, "globalavgpool1d", false, nil, nil}}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the GlobalAveragePooling1DLayer to the given node.
+func (l *GlobalAveragePooling1DLayer) Attach(x *G.Node) (*G.Node, error) {
+	on, err := attachGlobalPool1D(l.Name(), x, true)
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, err
+}
+
+// MustAttach attaches the GlobalAveragePooling1DLayer to the given node.
+func (l *GlobalAveragePooling1DLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *GlobalAveragePooling1DLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
+
+// GlobalMaxPooling1DLayer takes the max of each feature over every timestep of a sequence.
+// Timesteps that are masked (see Masking) are ignored.
+//   - Input Shape: (batch_size, timesteps, num_features)
+//   - Output Shape: (batch_size, num_features)
+//
+// Note that this pools over axis 1, like the recurrent layers, rather than the last axis like MaxPooling1D.
+type GlobalMaxPooling1DLayer struct {
+	LayerBase
+}
+
+// GlobalMaxPooling1D creates a new global max pooling layer for sequences on the specified model.
+func GlobalMaxPooling1D(m *Model, name string) *GlobalMaxPooling1DLayer {
+	l := &GlobalMaxPooling1DLayer{LayerBase{m.Graph, name, "globalmaxpool1d", false, nil, nil}}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the GlobalMaxPooling1DLayer to the given node.
+func (l *GlobalMaxPooling1DLayer) Attach(x *G.Node) (*G.Node, error) {
+	on, err := attachGlobalPool1D(l.Name(), x, false)
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, err
+}
+
+// MustAttach attaches the GlobalMaxPooling1DLayer to the given node.
+func (l *GlobalMaxPooling1DLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *GlobalMaxPooling1DLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
+
+// MaxPooling1DLayer is a max pooling layer for 1D signals.
+//   - Input Shape: (batch_size, num_channels, length)
+//   - Output Shape: (batch_size, num_channels, length) [length will be smaller than the input]
+type MaxPooling1DLayer struct {
+	LayerBase
+	PoolSize int
+	Stride   int
+	Padding  string
+}
+
+// SimpleMaxPooling1D creates a new 1D max pooling layer on the specified model.
+// It will have padding=same stride=poolSize.
+func SimpleMaxPooling1D(m *Model, name string, poolSize int) *MaxPooling1DLayer {
+	return MaxPooling1D(m, name, poolSize, poolSize, "same")
+}
+
+// MaxPooling1D creates a new 1D max pooling layer on the specified model.
+// Padding can be either "same" or "valid".
+func MaxPooling1D(m *Model, name string, poolSize, stride int, padding string) *MaxPooling1DLayer {
+	l := &MaxPooling1DLayer{
+		LayerBase{m.Graph, name, "maxpool1d", false, nil, nil},
+		poolSize,
+		stride,
+		padding,
+	}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the MaxPooling1DLayer to the given node.
+func (l *MaxPooling1DLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(3)); err != nil {
+		return nil, err
+	}
+	on, err := attachPoolND(l.Name(), x, false, []int{l.PoolSize}, []int{l.Stride}, l.Padding)
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, err
+}
+
+// MustAttach attaches the MaxPooling1DLayer to the given node.
+func (l *MaxPooling1DLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *MaxPooling1DLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
+
+// MaxPooling3DLayer is a max pooling layer for volumetric data.
+//   - Input Shape: (batch_size, num_channels, depth, height, width)
+//   - Output Shape: (batch_size, num_channels, depth, height, width) [depth, height and width will be smaller than the input]
+type MaxPooling3DLayer struct {
+	LayerBase
+	PoolSize []int
+	Stride   []int
+	Padding  string
+}
+
+// SimpleMaxPooling3D creates a new 3D max pooling layer on the specified model.
+// It will have padding=same stride=poolSize, and it is the same in all three dims.
+func SimpleMaxPooling3D(m *Model, name string, poolSize int) *MaxPooling3DLayer {
+	size := []int{poolSize, poolSize, poolSize}
+	return MaxPooling3D(m, name, size, size, "same")
+}
+
+// MaxPooling3D creates a new 3D max pooling layer on the specified model.
+// Padding can be either "same" or "valid".
+func MaxPooling3D(m *Model, name string, poolSize, stride []int, padding string) *MaxPooling3DLayer {
+	l := &MaxPooling3DLayer{
+		LayerBase{m.Graph, name, "maxpool3d", false, nil, nil},
+		poolSize,
+		stride,
+		padding,
+	}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the MaxPooling3DLayer to the given node.
+func (l *MaxPooling3DLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(5)); err != nil {
+		return nil, err
+	}
+	on, err := attachPoolND(l.Name(), x, false, l.PoolSize, l.Stride, l.Padding)
+	l.OutputNode = on
+	l.InputNodes = []*G.Node{x}
+	return on, err
+}
+
+// MustAttach attaches the MaxPooling3DLayer to the given node.
+func (l *MaxPooling3DLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *MaxPooling3DLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
+
+// attachPoolND applies max or average pooling with any number of spatial dims to x.
+// Like MaxPooling2D, "same" padding is worked out with calculateSamePadding, so the output length is ceil(length/stride).
+func attachPoolND(name string, x *G.Node, average bool, poolSize, stride []int, padding string) (*G.Node, error) {
+	if len(poolSize) != x.Dims()-2 || len(stride) != x.Dims()-2 {
+		return nil, fmt.Errorf("pool size %v and stride %v must both have %v dims", poolSize, stride, x.Dims()-2)
+	}
+	for i := range poolSize {
+		if poolSize[i] < 1 || stride[i] < 1 {
+			return nil, fmt.Errorf("pool size and stride must be positive, got %v and %v", poolSize, stride)
+		}
+	}
+	padBefore, padAfter := make([]int, len(poolSize)), make([]int, len(poolSize)) // padding=valid
+	switch padding {
+	case "same":
+		for i := range poolSize {
+			// G.MaxPool2D puts the second (larger) half of the padding before the input, so do the same here to match MaxPooling2D
+			pad := calculateSamePadding(x.Shape()[i+2], poolSize[i], stride[i])
+			padBefore[i], padAfter[i] = pad[1], pad[0]
+		}
+	case "valid":
+	default:
+		return nil, fmt.Errorf("padding must be either 'same' or 'valid' but got '%v'", padding)
+	}
+	op, err := newPoolNDOp(x.Shape(), average, poolSize, stride, padBefore, padAfter)
+	if err != nil {
+		return nil, err
+	}
+	on, err := G.ApplyOp(op, x)
+	if err != nil {
+		return nil, err
+	}
+	if average {
+		G.WithName(name + ".avgpool")(on)
+	} else {
+		G.WithName(name + ".maxpool")(on)
+	}
+	return on, nil
+}
+
+// attachGlobalPool2D pools over the whole of each channel of x, returning a (batch_size, num_channels) node.
+func attachGlobalPool2D(name string, x *G.Node, average bool) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(4)); err != nil {
+		return nil, err
+	}
+	size := []int{x.Shape()[2], x.Shape()[3]}
+	on, err := attachPoolND(name, x, average, size, size, "valid")
+	if err != nil {
+		return nil, err
+	}
+	return reshape(on, T.Shape{x.Shape()[0], x.Shape()[1]})
+}
+
+// attachGlobalPool1D pools over every timestep of x (batch_size, timesteps, num_features), returning a (batch_size, num_features) node.
+// If x has a mask, the masked timesteps are left out.
+func attachGlobalPool1D(name string, x *G.Node, average bool) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(3)); err != nil {
+		return nil, err
+	}
+	mask := sequenceMask(x)
+	var on *G.Node
+	var err error
+	switch {
+	case mask == nil && average:
+		on, err = G.Mean(x, 1)
+	case mask == nil:
+		on, err = G.Max(x, 1)
+	case average:
+		on, err = maskedAveragePool1D(name, x, mask)
+	default:
+		on, err = maskedMaxPool1D(name, x, mask)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if average {
+		G.WithName(name + ".avgpool")(on)
+	} else {
+		G.WithName(name + ".maxpool")(on)
+	}
+	return on, nil
+}
+
+// maskedAveragePool1D sums the timesteps of x that are not masked, and divides by the number of them.
+func maskedAveragePool1D(name string, x, mask *G.Node) (*G.Node, error) {
+	masked, err := multiplyByMask(x, mask)
+	if err != nil {
+		return nil, err
+	}
+	total, err := G.Sum(masked, 1)
+	if err != nil {
+		return nil, err
+	}
+	count, err := G.Sum(mask, 1)
+	if err != nil {
+		return nil, err
+	}
+	if count, err = atLeastOne(name, count); err != nil {
+		return nil, err
+	}
+	return G.BroadcastHadamardDiv(total, count, nil, []byte{1})
+}
+
+// maskedMaxPool1D takes the max of the timesteps of x after adding a large negative number to the ones that are masked.
+// If every timestep of a sample is masked, its output will be very negative.
+func maskedMaxPool1D(name string, x, mask *G.Node) (*G.Node, error) {
+	one := G.NewConstant(castVal(x.Dtype(), 1), G.WithName(name+".mask_one"))
+	big := G.NewConstant(castVal(x.Dtype(), 1e9), G.WithName(name+".mask_big"))
+	penalty, err := G.Sub(mask, one)
+	if err != nil {
+		return nil, err
+	}
+	if penalty, err = G.Mul(penalty, big); err != nil {
+		return nil, err
+	}
+	penalised, err := G.BroadcastAdd(x, penalty, nil, []byte{2})
+	if err != nil {
+		return nil, err
+	}
+	return G.Max(penalised, 1)
+}
+
 // This function calculates the padding for "same".
 // I borrowed the calculations from here: https://www.pico.net/kb/what-is-the-difference-between-same-and-valid-padding-in-tf-nn-max-pool-of-tensorflow/
 func calculateSamePadding(width, filterSize, stride int) []int {
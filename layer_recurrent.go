@@ -0,0 +1,119 @@
+package goras
+
+import (
+	"fmt"
+
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// This file contains the code that is shared between all recurrent layers.
+
+// recurrentStep computes the new states of a recurrent layer, given the input at a single timestep and the previous states.
+// The first returned state is used as the output of the layer for that timestep.
+type recurrentStep func(xt *G.Node, states []*G.Node) ([]*G.Node, error)
+
+// recurrentStates checks the initial states supplied by the user, or creates zeroed ones if none were supplied.
+func recurrentStates(name string, x *G.Node, units, numStates int, given []*G.Node) ([]*G.Node, error) {
+	batchSize := x.Shape()[0]
+	if len(given) == 0 {
+		states := make([]*G.Node, numStates)
+		for i := range states {
+			zeros := T.New(T.WithShape(batchSize, units), T.Of(x.Dtype()))
+			states[i] = G.NewConstant(zeros, G.WithName(fmt.Sprintf("%s.initial_state_%v", name, i)))
+		}
+		return states, nil
+	}
+	if len(given) != numStates {
+		return nil, fmt.Errorf("expected %v initial states but got %v", numStates, len(given))
+	}
+	for i, s := range given {
+		if err := validateShape(s.Shape(), valNDims(2), valNthDim(0, batchSize), valNthDim(1, units)); err != nil {
+			return nil, fmt.Errorf("initial state %v: %v", i, err)
+		}
+	}
+	return given, nil
+}
+
+// unrollRecurrent applies step to every timestep of x (batch_size, timesteps, features).
+// It returns either the final output (batch_size, units) or every output stacked together (batch_size, timesteps, units).
+// If mask (batch_size, timesteps) is not nil, the states are not changed by masked timesteps, so the final output is the output of the last timestep that was not masked.
+// The outputs of masked timesteps are zero when returning sequences.
+func unrollRecurrent(x *G.Node, states []*G.Node, mask *G.Node, returnSequences bool, step recurrentStep) (*G.Node, error) {
+	timesteps := x.Shape()[1]
+	outputs := make([]*G.Node, timesteps)
+	for t := 0; t < timesteps; t++ {
+		xt, err := sliceAxis(x, 1, t, t+1)
+		if err != nil {
+			return nil, err
+		}
+		xt, err = reshape(xt, T.Shape{x.Shape()[0], x.Shape()[2]})
+		if err != nil {
+			return nil, err
+		}
+		newStates, err := step(xt, states)
+		if err != nil {
+			return nil, err
+		}
+		if mask == nil {
+			states = newStates
+			outputs[t] = states[0]
+			continue
+		}
+		mt, err := sliceAxis(mask, 1, t, t+1)
+		if err != nil {
+			return nil, err
+		}
+		mt, err = reshape(mt, T.Shape{x.Shape()[0]})
+		if err != nil {
+			return nil, err
+		}
+		for i := range states {
+			// state = old + mask * (updated - old), so masked timesteps keep the old state
+			if states[i], err = maskedUpdate(states[i], newStates[i], mt); err != nil {
+				return nil, err
+			}
+		}
+		outputs[t] = states[0]
+		if returnSequences {
+			if outputs[t], err = G.BroadcastHadamardProd(states[0], mt, nil, []byte{1}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if !returnSequences {
+		return outputs[timesteps-1], nil
+	}
+	for t, o := range outputs {
+		s := o.Shape()
+		o, err := reshape(o, T.Shape{s[0], 1, s[1]})
+		if err != nil {
+			return nil, err
+		}
+		outputs[t] = o
+	}
+	if timesteps == 1 {
+		// G.Concat of a single node shares the data of that node, so we don't use it here
+		return outputs[0], nil
+	}
+	return G.Concat(1, outputs...)
+}
+
+// maskedUpdate returns old + mask * (updated - old), where mask (batch_size) is 1 for the rows that should be updated.
+func maskedUpdate(old, updated, mask *G.Node) (*G.Node, error) {
+	diff, err := G.Sub(updated, old)
+	if err != nil {
+		return nil, err
+	}
+	diff, err = G.BroadcastHadamardProd(diff, mask, nil, []byte{1})
+	if err != nil {
+		return nil, err
+	}
+	return G.Add(old, diff)
+}
+
+// sliceGate returns the columns [i*units, (i+1)*units) of x.
+// This is used to split up the fused gate weights of the LSTM and GRU layers.
+func sliceGate(x *G.Node, i, units int) (*G.Node, error) {
+	return sliceAxis(x, 1, i*units, (i+1)*units)
+}
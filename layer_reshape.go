@@ -1,11 +1,14 @@
 package goras
 
 import (
+	"fmt"
+
 	G "gorgonia.org/gorgonia"
 	T "gorgonia.org/tensor"
 )
 
 // ReshapeLayer is a reshape layer.
+// One of the dims of the target shape may be -1, in which case it is inferred from the size of the input (e.g. (-1, 10) or (batch_size, -1)).
 //   - Input Shape: any shape
 //   - Output Shape: the specified shape [as long as both shapes have the same volume]
 type ReshapeLayer struct {
@@ -25,10 +28,14 @@ func Reshape(model *Model, name string, newShape T.Shape) *ReshapeLayer {
 
 // Attach attaches the ReshapeLayer to the given node.
 func (l *ReshapeLayer) Attach(n *G.Node) (*G.Node, error) {
-	if err := validateShape(n.Shape(), valMatchingVolume(l.ToShape)); err != nil {
+	toShape, err := inferShape(n.Shape(), l.ToShape)
+	if err != nil {
+		return nil, err
+	}
+	if err := validateShape(n.Shape(), valMatchingVolume(toShape)); err != nil {
 		return nil, err
 	}
-	on, err := G.Reshape(n, l.ToShape)
+	on, err := reshape(n, toShape)
 	l.OutputNode = on
 	if on != nil {
 		G.WithName(l.Name() + ".reshape")(on)
@@ -44,3 +51,29 @@ func (l *ReshapeLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
 func (l *ReshapeLayer) Parameters() map[string]*G.Node {
 	return make(map[string]*G.Node)
 }
+
+// inferShape replaces a -1 in the target shape with whatever size makes it the same volume as the input shape.
+func inferShape(from, to T.Shape) (T.Shape, error) {
+	inferred := to.Clone()
+	inferAxis, knownSize := -1, 1
+	for i, d := range to {
+		switch {
+		case d == -1 && inferAxis == -1:
+			inferAxis = i
+		case d == -1:
+			return nil, fmt.Errorf("only one dim can be inferred but got shape %v", to)
+		case d < 1:
+			return nil, fmt.Errorf("dims must be greater than 0 (or -1 to infer them) but got shape %v", to)
+		default:
+			knownSize *= d
+		}
+	}
+	if inferAxis == -1 {
+		return inferred, nil
+	}
+	if from.TotalSize()%knownSize != 0 {
+		return nil, fmt.Errorf("cannot infer the missing dim of %v from input shape %v", to, from)
+	}
+	inferred[inferAxis] = from.TotalSize() / knownSize
+	return inferred, nil
+}
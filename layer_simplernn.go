@@ -0,0 +1,109 @@
+package goras
+
+import (
+	G "gorgonia.org/gorgonia"
+)
+
+// SimpleRNNLayer is a fully connected recurrent layer, where the output is fed back in as an input on the next timestep.
+// It computes h_t = tanh(x_t*kernel + h_(t-1)*recurrent_kernel + bias).
+// Timesteps that are masked (see Masking) are skipped, and are zero in the output sequence.
+//   - Input Shape: (batch_size, timesteps, num_features)
+//   - Output Shape: (batch_size, units) or (batch_size, timesteps, units) if ReturnSequences is true
+//   - Initial States (optional): [(batch_size, units)]
+type SimpleRNNLayer struct {
+	LayerBase
+	Kernel              *G.Node
+	RecurrentKernel     *G.Node
+	Bias                *G.Node
+	Units               int
+	ReturnSequences     bool
+	Initializer         G.InitWFn   // Used for both the kernel and the recurrent kernel
+	KernelRegularizer   Regularizer // Used for both the kernel and the recurrent kernel
+	ActivityRegularizer Regularizer
+}
+
+// SimpleRNN creates a new simple recurrent layer on the specified model.
+// It returns only the final output, and the weights are initialised with the model's default initializer. These can be changed by setting the fields before calling Attach.
+func SimpleRNN(m *Model, name string, units int) *SimpleRNNLayer {
+	l := &SimpleRNNLayer{
+		LayerBase:   LayerBase{m.Graph, name, "simplernn", true, nil, nil},
+		Units:       units,
+		Initializer: m.DefaultInitializer,
+	}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the layer to a previous node. The initial state is all zeros.
+func (l *SimpleRNNLayer) Attach(x *G.Node) (*G.Node, error) {
+	return l.AttachWithInitialStates(x)
+}
+
+// AttachWithInitialStates attaches the layer to a previous node, using the given node as the initial state.
+func (l *SimpleRNNLayer) AttachWithInitialStates(x *G.Node, initialStates ...*G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(3)); err != nil {
+		return nil, err
+	}
+	states, err := recurrentStates(l.Name(), x, l.Units, 1, initialStates)
+	if err != nil {
+		return nil, err
+	}
+	numFeatures := x.Shape()[2]
+	l.Kernel = G.NewMatrix(l.Graph, x.Dtype(), G.WithShape(numFeatures, l.Units), G.WithInit(initializerOrDefault(l.Initializer)), G.WithName(l.Name()+".kernel"))
+	l.RecurrentKernel = G.NewMatrix(l.Graph, x.Dtype(), G.WithShape(l.Units, l.Units), G.WithInit(initializerOrDefault(l.Initializer)), G.WithName(l.Name()+".recurrent_kernel"))
+	l.Bias = G.NewVector(l.Graph, x.Dtype(), G.WithShape(l.Units), G.WithInit(G.Zeroes()), G.WithName(l.Name()+".bias"))
+	mask := sequenceMask(x)
+	on, err := unrollRecurrent(x, states, mask, l.ReturnSequences, l.step)
+	if err != nil {
+		return nil, err
+	}
+	G.WithName(l.Name() + ".simplernn")(on)
+	if l.ReturnSequences && mask != nil {
+		SetMask(on, mask)
+	}
+	l.OutputNode = on
+	l.InputNodes = append([]*G.Node{x}, initialStates...)
+	return on, nil
+}
+
+func (l *SimpleRNNLayer) step(xt *G.Node, states []*G.Node) ([]*G.Node, error) {
+	xw, err := affine(xt, l.Kernel, l.Bias)
+	if err != nil {
+		return nil, err
+	}
+	hu, err := G.Mul(states[0], l.RecurrentKernel)
+	if err != nil {
+		return nil, err
+	}
+	sum, err := G.Add(xw, hu)
+	if err != nil {
+		return nil, err
+	}
+	h, err := G.Tanh(sum)
+	if err != nil {
+		return nil, err
+	}
+	return []*G.Node{h}, nil
+}
+
+// MustAttach attaches the layer to a previous node. It panics on error.
+func (l *SimpleRNNLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// MustAttachWithInitialStates attaches the layer to a previous node, using the given node as the initial state. It panics on error.
+func (l *SimpleRNNLayer) MustAttachWithInitialStates(n *G.Node, initialStates ...*G.Node) *G.Node {
+	on, err := l.AttachWithInitialStates(n, initialStates...)
+	if err != nil {
+		panic(err)
+	}
+	return on
+}
+
+// Parameters returns a map of the parameters of the layer.
+func (l *SimpleRNNLayer) Parameters() map[string]*G.Node {
+	return map[string]*G.Node{"kernel": l.Kernel, "recurrent_kernel": l.RecurrentKernel, "bias": l.Bias}
+}
+
+// RegularizationTerms returns the terms that the regularizers of the layer add to the loss.
+func (l *SimpleRNNLayer) RegularizationTerms() (map[string]*G.Node, error) {
+	return layerRegularizationTerms(l.KernelRegularizer, []*G.Node{l.Kernel, l.RecurrentKernel}, l.ActivityRegularizer, l.OutputNode)
+}
@@ -0,0 +1,98 @@
+package goras
+
+import (
+	"fmt"
+
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// TransformerEncoderBlock is a helper that builds a transformer encoder block out of other layers.
+// It is not a layer itself. Instead, when it is attached, it adds a self-attention layer, a two layer feed-forward network, residual adds and layer norms to the model.
+// All of these layers have names that start with the name of the block (e.g. "block_attention", "block_ff1").
+//   - Input/Output Shape: (batch_size, sequence_length, dim)
+//   - Mask Shape (optional): (batch_size, sequence_length, sequence_length)
+type TransformerEncoderBlock struct {
+	Model              *Model
+	Name               string
+	NumHeads           int
+	KeyDim             int
+	FeedForwardDim     int
+	DropoutProbability float64
+	Attention          *MultiHeadAttentionLayer
+}
+
+// TransformerEncoder creates a new transformer encoder block for the specified model.
+// There is no dropout by default, but this can be changed by setting DropoutProbability before calling Attach. It is applied to the attention weights.
+func TransformerEncoder(m *Model, name string, numHeads, keyDim, feedForwardDim int) *TransformerEncoderBlock {
+	return &TransformerEncoderBlock{
+		Model:          m,
+		Name:           name,
+		NumHeads:       numHeads,
+		KeyDim:         keyDim,
+		FeedForwardDim: feedForwardDim,
+	}
+}
+
+// Attach adds the layers of the block to the model, taking x and an optional attention mask as inputs.
+func (b *TransformerEncoderBlock) Attach(ns ...*G.Node) (*G.Node, error) {
+	if len(ns) != 1 && len(ns) != 2 {
+		return nil, fmt.Errorf("transformer encoder needs 1 (x) or 2 (x, mask) inputs but got %v", len(ns))
+	}
+	x := ns[0]
+	if err := validateShape(x.Shape(), valNDims(3)); err != nil {
+		return nil, err
+	}
+	seqLen, dim := x.Shape()[1], x.Shape()[2]
+	m := b.Model
+
+	// Self-attention, with a residual connection and layer norm
+	b.Attention = MultiHeadAttention(m, b.Name+"_attention", b.NumHeads, b.KeyDim)
+	b.Attention.DropoutProbability = b.DropoutProbability
+	attentionInputs := []*G.Node{x, x, x}
+	if len(ns) == 2 {
+		attentionInputs = append(attentionInputs, ns[1])
+	}
+	attended, err := b.Attention.Attach(attentionInputs...)
+	if err != nil {
+		return nil, err
+	}
+	attended, err = Add(m, b.Name+"_add1").Attach(x, attended)
+	if err != nil {
+		return nil, err
+	}
+	attended, err = LayerNorm(m, b.Name+"_norm1").Attach(attended)
+	if err != nil {
+		return nil, err
+	}
+
+	// Feed-forward network applied to each position separately, with a residual connection and layer norm
+	ff, err := Reshape(m, b.Name+"_flatten", T.Shape{-1, dim}).Attach(attended)
+	if err != nil {
+		return nil, err
+	}
+	ff, err = Dense(m, b.Name+"_ff1", b.FeedForwardDim).Attach(ff)
+	if err != nil {
+		return nil, err
+	}
+	ff, err = Relu(m, b.Name+"_relu").Attach(ff)
+	if err != nil {
+		return nil, err
+	}
+	ff, err = Dense(m, b.Name+"_ff2", dim).Attach(ff)
+	if err != nil {
+		return nil, err
+	}
+	ff, err = Reshape(m, b.Name+"_unflatten", T.Shape{-1, seqLen, dim}).Attach(ff)
+	if err != nil {
+		return nil, err
+	}
+	out, err := Add(m, b.Name+"_add2").Attach(attended, ff)
+	if err != nil {
+		return nil, err
+	}
+	return LayerNorm(m, b.Name+"_norm2").Attach(out)
+}
+
+// MustAttach adds the layers of the block to the model, taking x and an optional attention mask as inputs. It panics on error.
+func (b *TransformerEncoderBlock) MustAttach(ns ...*G.Node) *G.Node { return mustAttachMulti(b, ns...) }
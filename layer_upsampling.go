@@ -0,0 +1,60 @@
+package goras
+
+import (
+	"fmt"
+
+	G "gorgonia.org/gorgonia"
+)
+
+// UpSampling2DLayer is a layer that scales up images by integer factors.
+//   - Input Shape: (batch_size, num_channels, img_height, img_width)
+//   - Output Shape: (batch_size, num_channels, img_height*size[0], img_width*size[1])
+type UpSampling2DLayer struct {
+	LayerBase
+	Size          []int
+	Interpolation string
+}
+
+// SimpleUpSampling2D creates a new upsampling layer on the specified model.
+// It scales both dims by size, and uses "nearest" interpolation.
+func SimpleUpSampling2D(m *Model, name string, size int) *UpSampling2DLayer {
+	return UpSampling2D(m, name, []int{size, size}, "nearest")
+}
+
+// UpSampling2D creates a new upsampling layer on the specified model.
+// Interpolation can be either "nearest" or "bilinear".
+func UpSampling2D(m *Model, name string, size []int, interpolation string) *UpSampling2DLayer {
+	l := &UpSampling2DLayer{
+		LayerBase{m.Graph, name, "upsampling2d", false, nil, nil},
+		size,
+		interpolation,
+	}
+	m.AddLayer(l)
+	return l
+}
+
+// Attach attaches the UpSampling2DLayer to the given node.
+func (l *UpSampling2DLayer) Attach(x *G.Node) (*G.Node, error) {
+	if err := validateShape(x.Shape(), valNDims(4)); err != nil {
+		return nil, err
+	}
+	if len(l.Size) != 2 || l.Size[0] < 1 || l.Size[1] < 1 {
+		return nil, fmt.Errorf("size must be 2 positive integers, got %v", l.Size)
+	}
+	if l.Interpolation != "nearest" && l.Interpolation != "bilinear" {
+		return nil, fmt.Errorf("interpolation must be either 'nearest' or 'bilinear', got '%v'", l.Interpolation)
+	}
+	on, err := G.ApplyOp(&upSample2DOp{x.Shape().Clone(), l.Size, l.Interpolation == "bilinear"}, x)
+	l.OutputNode = on
+	if on != nil {
+		G.WithName(l.Name() + ".upsample")(on)
+	}
+	l.InputNodes = []*G.Node{x}
+	return on, err
+}
+
+// MustAttach attaches the UpSampling2DLayer to the given node. It panics on error.
+func (l *UpSampling2DLayer) MustAttach(n *G.Node) *G.Node { return mustAttach(l, n) }
+
+// Parameters returns a map of the parameters of the layer.
+func (l *UpSampling2DLayer) Parameters() map[string]*G.Node { return map[string]*G.Node{} }
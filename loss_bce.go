@@ -6,7 +6,7 @@ import G "gorgonia.org/gorgonia"
 // It should be used when using Model.Build().
 func BCELoss(targetName string, output *G.Node) LossFunc {
 	return func() (*G.Node, map[string]*G.Node, error) {
-		target := G.NewMatrix(output.Graph(), output.Dtype(), G.WithShape(output.Shape()...), G.WithName(targetName))
+		target := G.NewTensor(output.Graph(), output.Dtype(), output.Dims(), G.WithShape(output.Shape()...), G.WithName(targetName))
 		x1, err := G.Log(output)
 		if err != nil {
 			return nil, nil, err
@@ -6,9 +6,12 @@ import (
 	G "gorgonia.org/gorgonia"
 )
 
+// CCELoss creates the nodes to calculate categorical cross-entropy loss between a predicted and target node.
+// The classes are along the last axis, so the output can be (batch_size, num_classes) or, for sequences, (batch_size, timesteps, num_classes).
+// If the output has a mask (see Masking), the masked timesteps are ignored.
 func CCELoss(targetName string, output *G.Node) LossFunc {
 	return func() (*G.Node, map[string]*G.Node, error) {
-		target := G.NewMatrix(output.Graph(), output.Dtype(), G.WithShape(output.Shape()...), G.WithName(targetName))
+		target := G.NewTensor(output.Graph(), output.Dtype(), output.Dims(), G.WithShape(output.Shape()...), G.WithName(targetName))
 		x, err := G.Log(output)
 		if err != nil {
 			return nil, nil, fmt.Errorf("CCE error while performing Log op: %v", err)
@@ -17,11 +20,15 @@ func CCELoss(targetName string, output *G.Node) LossFunc {
 		if err != nil {
 			return nil, nil, fmt.Errorf("CCE error while performing HardmanProd op: %v", err)
 		}
-		x, err = G.Sum(x, 1)
+		x, err = G.Sum(x, output.Dims()-1)
 		if err != nil {
 			return nil, nil, fmt.Errorf("CCE error while performing Sum op: %v", err)
 		}
-		x, err = G.Mean(x)
+		if mask := sequenceMask(output); mask != nil && output.Dims() > 2 {
+			x, err = maskedMean(targetName, x, mask)
+		} else {
+			x, err = G.Mean(x)
+		}
 		if err != nil {
 			return nil, nil, fmt.Errorf("CCE error while performing Mean op: %v", err)
 		}
@@ -4,9 +4,10 @@ import G "gorgonia.org/gorgonia"
 
 // MSE creates the nodes to calculate mean squared error loss between a predicted and target node.
 // It should be used when using Model.Build().
+// If the output has a mask (see Masking), the masked timesteps are ignored.
 func MSELoss(targetName string, output *G.Node) LossFunc {
 	return func() (*G.Node, map[string]*G.Node, error) {
-		target := G.NewMatrix(output.Graph(), output.Dtype(), G.WithShape(output.Shape()...), G.WithName(targetName))
+		target := G.NewTensor(output.Graph(), output.Dtype(), output.Dims(), G.WithShape(output.Shape()...), G.WithName(targetName))
 		x, err := G.Sub(output, target)
 		if err != nil {
 			return nil, nil, err
@@ -15,7 +16,12 @@ func MSELoss(targetName string, output *G.Node) LossFunc {
 		if err != nil {
 			return nil, nil, err
 		}
-		x, err = G.Mean(x)
+		// Padded timesteps from a Masking layer are not included in the mean
+		if mask := sequenceMask(output); mask != nil {
+			x, err = maskedMean(targetName, x, mask)
+		} else {
+			x, err = G.Mean(x)
+		}
 		if err != nil {
 			return nil, nil, err
 		}
@@ -0,0 +1,144 @@
+package goras
+
+import (
+	"sync"
+
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// This file contains the code for passing sequence masks between layers.
+// A mask is a (batch_size, timesteps) node, which is 1 for real timesteps and 0 for padding.
+// It is created by the Masking layer, and then passed along by layers that keep the timesteps the same (e.g. activations, dropout and recurrent layers that return sequences).
+// Recurrent layers, attention, sequence pooling and the MSE and CCE losses ignore any timesteps that are masked.
+
+// masks holds the mask of each masked node, grouped by graph. The masks of a graph are forgotten when its model is built, whether or not building succeeds.
+var masks = struct {
+	sync.Mutex
+	byGraph map[*G.ExprGraph]map[*G.Node]*G.Node
+}{byGraph: make(map[*G.ExprGraph]map[*G.Node]*G.Node)}
+
+// MaskOf returns the mask of the node n, or nil if it does not have one.
+// This is useful for custom layers, which can use it to ignore padded timesteps, and pass it on to their output with SetMask.
+// Masks are only kept until the model is built (or fails to build).
+func MaskOf(n *G.Node) *G.Node {
+	masks.Lock()
+	defer masks.Unlock()
+	return masks.byGraph[n.Graph()][n]
+}
+
+// SetMask sets the mask of the node n, which should be a (batch_size, timesteps) node of 1s and 0s. If mask is nil, the mask of n is removed.
+func SetMask(n, mask *G.Node) {
+	masks.Lock()
+	defer masks.Unlock()
+	g := n.Graph()
+	if mask == nil {
+		delete(masks.byGraph[g], n)
+		return
+	}
+	if masks.byGraph[g] == nil {
+		masks.byGraph[g] = make(map[*G.Node]*G.Node)
+	}
+	masks.byGraph[g][n] = mask
+}
+
+// forgetMasks removes all of the masks of the graph g.
+func forgetMasks(g *G.ExprGraph) {
+	masks.Lock()
+	defer masks.Unlock()
+	delete(masks.byGraph, g)
+}
+
+// sequenceMask returns the mask of x if it has one that matches the first two dims of x, otherwise nil.
+func sequenceMask(x *G.Node) *G.Node {
+	mask := MaskOf(x)
+	if mask == nil || x.Dims() < 2 || !exactShapeEq(mask.Shape(), x.Shape()[:2]) {
+		return nil
+	}
+	return mask
+}
+
+// passMask gives on the same mask as x, if x has one and on still has the same timesteps.
+func passMask(x, on *G.Node) {
+	if mask := sequenceMask(x); mask != nil && on.Dims() >= 2 && exactShapeEq(mask.Shape(), on.Shape()[:2]) {
+		SetMask(on, mask)
+	}
+}
+
+// mergeMasks gives on the combination of the masks of the inputs, where a timestep is only kept if it is kept in every input.
+// Inputs without a mask are ignored, and if none of them have one, on is not given one either.
+func mergeMasks(on *G.Node, inputs ...*G.Node) error {
+	var mask *G.Node
+	for _, x := range inputs {
+		xMask := sequenceMask(x)
+		switch {
+		case xMask == nil || xMask == mask:
+		case mask == nil:
+			mask = xMask
+		default:
+			var err error
+			if mask, err = G.HadamardProd(mask, xMask); err != nil {
+				return err
+			}
+		}
+	}
+	if mask != nil && on.Dims() >= 2 && exactShapeEq(mask.Shape(), on.Shape()[:2]) {
+		SetMask(on, mask)
+	}
+	return nil
+}
+
+// multiplyByMask multiplies every timestep of x by its mask value, so that masked timesteps become zero.
+func multiplyByMask(x, mask *G.Node) (*G.Node, error) {
+	if x.Dims() == 2 {
+		return G.HadamardProd(x, mask)
+	}
+	pattern := make([]byte, 0, x.Dims()-2)
+	for i := 2; i < x.Dims(); i++ {
+		pattern = append(pattern, byte(i))
+	}
+	return G.BroadcastHadamardProd(x, mask, nil, pattern)
+}
+
+// maskedMean returns the mean of x (batch_size, timesteps, ...other_dims) over all of the timesteps that are not masked.
+// Each timestep is first averaged over its other dims. If every timestep is masked, the result is zero.
+func maskedMean(name string, x, mask *G.Node) (*G.Node, error) {
+	var err error
+	if x.Dims() > 2 {
+		x, err = reshape(x, T.Shape{x.Shape()[0], x.Shape()[1], x.Shape()[2:].TotalSize()})
+		if err != nil {
+			return nil, err
+		}
+		if x, err = G.Mean(x, 2); err != nil {
+			return nil, err
+		}
+	}
+	if x, err = G.HadamardProd(x, mask); err != nil {
+		return nil, err
+	}
+	total, err := G.Sum(x, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	count, err := G.Sum(mask, 0, 1)
+	if err != nil {
+		return nil, err
+	}
+	if count, err = atLeastOne(name, count); err != nil {
+		return nil, err
+	}
+	return G.Div(total, count)
+}
+
+// atLeastOne returns max(x, 1), which is used to avoid dividing by zero when every timestep is masked.
+func atLeastOne(name string, x *G.Node) (*G.Node, error) {
+	one := G.NewConstant(castVal(x.Dtype(), 1), G.WithName(name+".mask_one"))
+	x, err := G.Sub(x, one)
+	if err != nil {
+		return nil, err
+	}
+	if x, err = G.Rectify(x); err != nil {
+		return nil, err
+	}
+	return G.Add(x, one)
+}
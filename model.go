@@ -4,6 +4,8 @@ import (
 	"encoding/gob"
 	"fmt"
 	"io"
+	"path"
+	"sort"
 	"strings"
 
 	G "gorgonia.org/gorgonia"
@@ -21,11 +23,21 @@ type Model struct {
 	OutputValues      map[string]*G.Value // This is deliberately a ref because i think maps are scary
 	LossValue         G.Value
 	LossRequiredNodes map[string]*G.Node
+	// RegularizationValues holds the value of each regularization term from the last run, keyed by the layer name and term name, separated by a colon (e.g. "model_1:kernel").
+	RegularizationValues map[string]*G.Value
+	// DefaultInitializer is given to layers with weights when they are created, unless they are told to use something else. It is GlorotNormal by default.
+	DefaultInitializer G.InitWFn
+	// training is true while the model is being fit, and false while it is predicting.
+	training bool
+	// batchSizeModels holds a copy of the model for each other batch size that it has predicted with, which share its parameters.
+	batchSizeModels map[int]*Model
+	// batchSizeErrors holds the error for each batch size that the model could not be rebuilt for, so it is not rebuilt again.
+	batchSizeErrors map[int]error
 }
 
 // NewModel creates a new model with no layers
 func NewModel() *Model {
-	return &Model{Graph: G.NewGraph(), Layers: []Layer{}}
+	return &Model{Graph: G.NewGraph(), Layers: []Layer{}, DefaultInitializer: GlorotNormal()}
 }
 
 // AddLayer adds a layer to the model. You usually don't need to call this directly, as the layer constructors do it for you.
@@ -65,6 +77,8 @@ func WithLoss(loss LossFunc) BuildOpts {
 // It adds the loss function to the graph, and creates the machine.
 // This should only be called once per model.
 func (m *Model) Build(opts ...BuildOpts) error {
+	// The masks are only needed while the losses are added, and are forgotten even if building fails so that they do not keep the graph alive
+	defer forgetMasks(m.Graph)
 	buildParams := &buildParams{
 		inputNodes:  make(map[string]*G.Node),
 		outputNodes: make(map[string]*G.Node),
@@ -81,6 +95,8 @@ func (m *Model) Build(opts ...BuildOpts) error {
 
 	// Store input and output nodes
 	m.InputNodes = buildParams.inputNodes
+	m.batchSizeModels = nil
+	m.batchSizeErrors = nil
 	m.OutputNodes = buildParams.outputNodes
 	// Read the outputs to values
 	m.OutputValues = make(map[string]*G.Value, len(m.OutputNodes))
@@ -94,11 +110,16 @@ func (m *Model) Build(opts ...BuildOpts) error {
 	if err != nil {
 		return fmt.Errorf("error while adding loss: %v", err)
 	}
+	lossNode, err = m.addRegularization(lossNode)
+	if err != nil {
+		return fmt.Errorf("error while adding regularization: %v", err)
+	}
 	G.Read(lossNode, &m.LossValue)
 	m.LossRequiredNodes = lossRequiredNodes
-	trainables := m.Trainables()
-	if len(trainables) != 0 {
-		_, err = G.Grad(lossNode, trainables...)
+	// The grads of every parameter are calculated, even ones in layers that are not trainable right now, so that layers can be frozen and unfrozen after building.
+	params := m.parameterNodes(false)
+	if len(params) != 0 {
+		_, err = G.Grad(lossNode, params...)
 		if err != nil {
 			return fmt.Errorf("error while computing grad: %v", err)
 		}
@@ -107,6 +128,10 @@ func (m *Model) Build(opts ...BuildOpts) error {
 	// Check for duplicate node names
 	nodeNames := make(map[string]bool)
 	for _, n := range m.Graph.AllNodes() {
+		// Gorgonia creates SizeOf nodes when broadcasting, and these do not have unique names (two axes with the same size get the same name).
+		if strings.HasPrefix(n.Name(), "SizeOf=") {
+			continue
+		}
 		if _, ok := nodeNames[n.Name()]; ok {
 			return fmt.Errorf("duplicate node name %s, either there are two layers with the same name, or this is a bug (please report)", n.Name())
 		}
@@ -123,10 +148,61 @@ func (m *Model) Build(opts ...BuildOpts) error {
 	}
 
 	// Create machine
-	m.Machine = G.NewTapeMachine(m.Graph, G.BindDualValues(m.Trainables()...))
+	m.Machine = G.NewTapeMachine(m.Graph, G.BindDualValues(params...))
 	return nil
 }
 
+// addRegularization adds the regularization terms of every RegularizedLayer to the loss, and reads each of them into RegularizationValues.
+func (m *Model) addRegularization(lossNode *G.Node) (*G.Node, error) {
+	m.RegularizationValues = make(map[string]*G.Value)
+	for _, l := range m.Layers {
+		rl, ok := l.(RegularizedLayer)
+		if !ok {
+			continue
+		}
+		terms, err := rl.RegularizationTerms()
+		if err != nil {
+			return nil, fmt.Errorf("layer %s: %v", l.Name(), err)
+		}
+		// The terms are added in order of name, so that the graph is the same every time
+		names := make([]string, 0, len(terms))
+		for name := range terms {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			var val G.Value
+			G.Read(terms[name], &val)
+			m.RegularizationValues[l.Name()+":"+name] = &val
+			lossNode, err = G.Add(lossNode, terms[name])
+			if err != nil {
+				return nil, fmt.Errorf("layer %s: %v", l.Name(), err)
+			}
+		}
+	}
+	return lossNode, nil
+}
+
+// RegularizationLosses returns the value of each regularization term from the last time the model was run, keyed by the layer name and term name, separated by a colon (e.g. "model_1:kernel").
+// These are already included in the loss, but this shows how much each of them contributes.
+func (m *Model) RegularizationLosses() (map[string]float64, error) {
+	losses := make(map[string]float64, len(m.RegularizationValues))
+	for name, val := range m.RegularizationValues {
+		if *val == nil {
+			return nil, fmt.Errorf("the model has not been run yet")
+		}
+		switch v := (*val).Data().(type) {
+		case float64:
+			losses[name] = v
+		case float32:
+			losses[name] = float64(v)
+		default:
+			return nil, fmt.Errorf("unsupported regularization dtype %v, please use either float64 or float32", (*val).Dtype())
+		}
+	}
+	return losses, nil
+}
+
 // MustBuild calls Build, but panics if there is an error.
 func (m *Model) MustBuild(opts ...BuildOpts) {
 	err := m.Build(opts...)
@@ -137,17 +213,95 @@ func (m *Model) MustBuild(opts ...BuildOpts) {
 
 // Trainables returns a list of all the trainable nodes in the model.
 func (m *Model) Trainables() G.Nodes {
+	return m.parameterNodes(true)
+}
+
+// parameterNodes returns the parameters of every layer, or only the trainable layers if onlyTrainable is true.
+func (m *Model) parameterNodes(onlyTrainable bool) G.Nodes {
 	var ret G.Nodes
 	for _, l := range m.Layers {
-		if l.Trainable() {
-			for _, t := range l.Parameters() {
-				ret = append(ret, t)
+		if l.Trainable() || !onlyTrainable {
+			// The parameters are sorted by name, as the solvers rely on the order of the trainables staying the same between steps
+			params := l.Parameters()
+			names := make([]string, 0, len(params))
+			for name := range params {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				ret = append(ret, params[name])
 			}
 		}
 	}
 	return ret
 }
 
+// SetTrainable sets whether the layers with names matching pattern are updated when the model is fit.
+// The pattern can be a layer name, or a glob such as "encoder_*" to match every layer whose name starts with "encoder_" (see path.Match for the syntax).
+// This can be changed at any time, including after the model is built, so a model can be loaded with pretrained weights and then fine tuned with only some layers trainable.
+// It returns an error if no layers match the pattern.
+func (m *Model) SetTrainable(pattern string, trainable bool) error {
+	matched := 0
+	for _, l := range m.Layers {
+		ok, err := path.Match(pattern, l.Name())
+		if err != nil {
+			return err
+		}
+		if !ok {
+			continue
+		}
+		tl, ok := l.(trainableSetter)
+		if !ok {
+			return fmt.Errorf("layer %s does not support changing whether it is trainable", l.Name())
+		}
+		tl.SetTrainable(trainable)
+		matched++
+	}
+	if matched == 0 {
+		return fmt.Errorf("no layers match the pattern '%s'", pattern)
+	}
+	return nil
+}
+
+// MustSetTrainable calls SetTrainable, but panics if there is an error.
+func (m *Model) MustSetTrainable(pattern string, trainable bool) {
+	if err := m.SetTrainable(pattern, trainable); err != nil {
+		panic(err)
+	}
+}
+
+// step updates the parameters of the model with the solver, using the grads from the last run.
+// Every parameter is given to the solver (as solvers expect the same parameters every step), but the grads of layers that are not trainable are zeroed first, so stateful solvers (e.g. Adam) do not build up any history for them.
+// Zero grads can still move a parameter (e.g. with momentum or regularization), so the parameters of layers that are not trainable are also put back afterwards.
+func (m *Model) step(solver G.Solver) error {
+	frozen := make(map[*G.Node]T.Tensor)
+	for _, l := range m.Layers {
+		if l.Trainable() {
+			continue
+		}
+		for name, p := range l.Parameters() {
+			frozen[p] = p.Value().(T.Tensor).Clone().(T.Tensor)
+			grad, err := p.Grad()
+			if err != nil {
+				return fmt.Errorf("error getting the grad of %s: %v", l.Name()+":"+name, err)
+			}
+			if g, ok := grad.(T.Tensor); ok {
+				g.Zero()
+			}
+		}
+	}
+	if err := solver.Step(G.NodesToValueGrads(m.parameterNodes(false))); err != nil {
+		return err
+	}
+	// The values are copied back into the existing tensors, so parameters bound to other models (see BindParamsFrom) stay shared
+	for p, v := range frozen {
+		if err := T.Copy(p.Value().(T.Tensor), v); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
 // valueToTensor converts a G.Value to a tensor.
 // The tensor shares the same underlying data as the value, so changing the returned tensor will change the value.
 func valueToTensor(v G.Value) *T.Dense {
@@ -156,20 +310,70 @@ func valueToTensor(v G.Value) *T.Dense {
 
 // GetParams returns a map of all the parameters in the model.
 // The keys are the layer name and parameter name, separated by a colon (e.g. "model_1:weights")
+// The state of any StatefulLayer (e.g. the running statistics of batch norm) is also included.
 func (m *Model) GetParams() map[string]*T.Dense {
 	ret := make(map[string]*T.Dense)
 	for _, l := range m.Layers {
 		for k, v := range l.Parameters() {
 			ret[l.Name()+":"+k] = valueToTensor(v.Value())
 		}
+		if sl, ok := l.(StatefulLayer); ok {
+			for k, v := range sl.State() {
+				ret[l.Name()+":"+k] = v
+			}
+		}
 	}
 	return ret
 }
 
+// setState copies any matching state tensors from params into the stateful layers of the model.
+// State is always copied, as the layers hold onto their state tensors.
+func (m *Model) setState(params map[string]*T.Dense) error {
+	for _, l := range m.Layers {
+		sl, ok := l.(StatefulLayer)
+		if !ok {
+			continue
+		}
+		for k, v := range sl.State() {
+			if p, ok := params[l.Name()+":"+k]; ok {
+				if !exactShapeEq(v.Shape(), p.Shape()) {
+					return fmt.Errorf("error setting state %s: expected shape %v but got %v", l.Name()+":"+k, v.Shape(), p.Shape())
+				}
+				if err := T.Copy(v, p); err != nil {
+					return fmt.Errorf("error setting state %s: %s", l.Name()+":"+k, err)
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// IsTraining returns true if the model was last run by FitBatch (so layers such as Dropout are active), or false if it was last run by PredictBatch.
+func (m *Model) IsTraining() bool {
+	return m.training
+}
+
+// setTraining sets the training flag of the model, and tells every TrainModeLayer in the model whether it is training or predicting.
+func (m *Model) setTraining(isTraining bool) error {
+	m.training = isTraining
+	for _, l := range m.Layers {
+		if tl, ok := l.(TrainModeLayer); ok {
+			if err := tl.SetTraining(isTraining); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 // SetParams sets the parameters in the model, which can be retrieved with Model.GetParams.
 // It will only load parameters with matching names, and will ignore any others.
 // This means you can load parameters from a model with a different architecture, as long as the names match on equivalent layers.
 func (m *Model) SetParams(params map[string]*T.Dense) error {
+	params, err := m.upgradeParams(params)
+	if err != nil {
+		return err
+	}
 	for _, l := range m.Layers {
 		for k, v := range l.Parameters() {
 			if p, ok := params[l.Name()+":"+k]; ok {
@@ -179,7 +383,23 @@ func (m *Model) SetParams(params map[string]*T.Dense) error {
 			}
 		}
 	}
-	return nil
+	return m.setState(params)
+}
+
+// upgradeParams returns a copy of params, where any parameters saved by an older version of a layer have been converted to the current format.
+func (m *Model) upgradeParams(params map[string]*T.Dense) (map[string]*T.Dense, error) {
+	upgraded := make(map[string]*T.Dense, len(params))
+	for k, v := range params {
+		upgraded[k] = v
+	}
+	for _, l := range m.Layers {
+		if ll, ok := l.(legacyParamsLayer); ok {
+			if err := ll.upgradeParams(upgraded); err != nil {
+				return nil, fmt.Errorf("error upgrading parameters of %s: %s", l.Name(), err)
+			}
+		}
+	}
+	return upgraded, nil
 }
 
 // MustSetParams calls SetParams, but panics if there is an error.
@@ -227,6 +447,7 @@ func (m *Model) MustReadParams(r io.Reader) {
 
 // BindParamsFrom binds the parameters in the model m1 to the parameters in this model m, meaning layers with the same name will share the same tensors.
 // This is a bit of a hack to allow two models to train the same weights.
+// Note that the state of stateful layers (e.g. batch norm running statistics) is copied rather than shared.
 // This can be called multiple times, where later binds may override earlier ones.
 // For example, if you are making an autoencoder, you would have one main model for training, and an encoder model and decoder model which are bound to that.
 // That then allows you to run partial bits of the network.
@@ -241,7 +462,7 @@ func (m *Model) BindParamsFrom(m1 *Model) error {
 			}
 		}
 	}
-	return nil
+	return m.setState(paramsSrc)
 }
 
 // MustBindParamsFrom calls BindParamsFrom, but panics if there is an error.
@@ -267,7 +488,7 @@ func (m *Model) CopyParamsFrom(m1 *Model) error {
 			}
 		}
 	}
-	return nil
+	return m.setState(paramsSrc)
 }
 
 // MustCopyParamsFrom calls CopyParamsFrom, but panics if there is an error.
@@ -278,11 +499,35 @@ func (m *Model) MustCopyParamsFrom(m1 *Model) {
 	}
 }
 
-// PredictBatch runs the model on a batch of input data. The batch size must match the input node shape.
+// PredictBatch runs the model on a batch of input data.
+// The batch size does not have to match the input node shape. If it is different, a copy of the model is built for that batch size the first time it is used, and reused after that.
+// The copy shares the parameters of this model, so it always uses the latest parameters, but building it is slow, so it is best to only use a few different batch sizes.
 func (m *Model) PredictBatch(inputs map[string]T.Tensor) (map[string]T.Tensor, error) {
-	if err := checkBatchedInputShapes(m, inputs); err != nil {
+	batchSize, err := checkInputShapesExceptBatch(m, inputs)
+	if err != nil {
+		return nil, err
+	}
+	if err := m.setTraining(false); err != nil {
+		return nil, err
+	}
+	bm, err := m.modelForBatchSize(batchSize)
+	if err != nil {
 		return nil, err
 	}
+	if bm != m {
+		// The parameters are bound every time, as they may have been replaced (e.g. by SetParams) since the last time
+		if err := bm.BindParamsFrom(m); err != nil {
+			return nil, err
+		}
+		if err := bm.setTraining(false); err != nil {
+			return nil, err
+		}
+	}
+	return bm.runPredict(inputs)
+}
+
+// runPredict runs the machine of the model on a batch of inputs, which have already been checked.
+func (m *Model) runPredict(inputs map[string]T.Tensor) (map[string]T.Tensor, error) {
 	m.Machine.Reset()
 	for name := range inputs {
 		if err := G.Let(m.InputNodes[name], inputs[name]); err != nil {
@@ -329,6 +574,9 @@ func (m *Model) FitBatch(inputs, lossRequirements map[string]T.Tensor, solver G.
 	if err := checkBatchedLossRequirementShapes(m, lossRequirements); err != nil {
 		return 0, err
 	}
+	if err := m.setTraining(true); err != nil {
+		return 0, err
+	}
 	m.Machine.Reset()
 	for name := range inputs {
 		if err := G.Let(m.InputNodes[name], inputs[name]); err != nil {
@@ -343,7 +591,7 @@ func (m *Model) FitBatch(inputs, lossRequirements map[string]T.Tensor, solver G.
 	if err := m.Machine.RunAll(); err != nil {
 		return 0, err
 	}
-	if err := solver.Step(G.NodesToValueGrads(m.Trainables())); err != nil {
+	if err := m.step(solver); err != nil {
 		return 0, err
 	}
 	loss := 0.0
@@ -481,11 +729,37 @@ func (m *Model) MustFitGenerator(tdg TrainingDataGenerator, solver G.Solver, opt
 }
 
 // Predict returns the models outputs for the given inputs. It cuts the inputs into batches so the inputs can be of any length.
+// If the length is not a multiple of the batch size, the last batch is run with a smaller batch size (see PredictBatch).
+// If the model cannot be run with that batch size, the last batch is padded with zeros instead.
 func (m *Model) Predict(xs map[string]T.Tensor) (map[string]T.Tensor, error) {
-	xBatchess, numPads, err := batchMultipleTensors(xs, m.getCurrentBatchSize(), true)
+	batchSize := m.getCurrentBatchSize()
+	numRows := 0
+	for _, x := range xs {
+		numRows = x.Shape()[0]
+		break
+	}
+	remainder := numRows % batchSize
+	zeroPad := false
+	if remainder != 0 {
+		_, err := m.modelForBatchSize(remainder)
+		zeroPad = err != nil
+	}
+	xBatchess, numPads, err := batchMultipleTensors(xs, batchSize, zeroPad)
 	if err != nil {
 		return nil, err
 	}
+	if remainder != 0 && !zeroPad {
+		// The remainder was cut off, so it is added as a smaller last batch
+		lastBatch := make(map[string]T.Tensor, len(xs))
+		for name, x := range xs {
+			lastBatch[name], err = sliceBatch(x, T.S(numRows-remainder, numRows))
+			if err != nil {
+				return nil, err
+			}
+		}
+		xBatchess = append(xBatchess, lastBatch)
+		numPads = 0
+	}
 	yBatchess := make([]map[string]T.Tensor, len(xBatchess))
 	for bi := range xBatchess {
 		yBatches, err := m.PredictBatch(xBatchess[bi])
@@ -611,7 +885,7 @@ func sliceBatch(t T.Tensor, slice T.Slice) (T.Tensor, error) {
 		return nil, err
 	}
 	if len(st.Shape()) != len(origShape) {
-		newShape := origShape
+		newShape := origShape.Clone()
 		newShape[0] = 1
 		err = st.Reshape(newShape...)
 		if err != nil {
@@ -0,0 +1,253 @@
+package goras
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"math/rand"
+	"time"
+
+	"github.com/chewxy/hm"
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+var _ G.Op = &augmentOp{}
+var _ G.SDOp = &augmentOp{}
+var _ G.TrainModeOp = &augmentOp{}
+
+// augmentation is a transform applied by an augmentOp. Each output element i is:
+//
+//	x[index[i]] * scale[i] + noise[i]
+//
+// where an index of -1 means the output is zero (before the noise). Any of the slices can be nil, which means index[i] = i, scale[i] = 1 or noise[i] = 0.
+type augmentation struct {
+	index []int
+	scale []float64
+	noise []float64
+}
+
+// augmentSampler makes the transform for one forward pass of an augmentOp.
+// When training is false, it should make the transform used when predicting, which is usually nothing (a nil augmentation).
+type augmentSampler func(rng *rand.Rand, inShape T.Shape, training bool) augmentation
+
+// augmentOp randomly transforms its input each time it is run while training, for the noise and data augmentation layers.
+// The transform is remembered, so that the backwards pass can undo it.
+// Noise does not affect the gradient, and the gradient of each output is added to the input it was copied from, multiplied by its scale.
+type augmentOp struct {
+	name     string
+	inShape  T.Shape
+	outShape T.Shape
+	training bool
+	rng      *rand.Rand
+	sample   augmentSampler
+	// This is cached by the forward pass, and used by the backward pass.
+	last augmentation
+}
+
+// newAugmentOp creates an augment op that is in training mode.
+// If seed is 0, the random numbers are seeded with the current time.
+func newAugmentOp(name string, inShape, outShape T.Shape, seed int64, sample augmentSampler) *augmentOp {
+	if seed == 0 {
+		seed = time.Now().UnixNano()
+	}
+	return &augmentOp{
+		name:     name,
+		inShape:  inShape.Clone(),
+		outShape: outShape.Clone(),
+		training: true,
+		rng:      rand.New(rand.NewSource(seed)),
+		sample:   sample,
+	}
+}
+
+// SetTraining implements gorgonia.TrainModeOp.
+func (op *augmentOp) SetTraining(isTraining bool) error {
+	op.training = isTraining
+	return nil
+}
+
+// Arity implements gorgonia.Op.
+func (*augmentOp) Arity() int { return 1 }
+
+// Type implements gorgonia.Op.
+func (op *augmentOp) Type() hm.Type {
+	t := G.TensorType{Dims: op.inShape.Dims(), Of: hm.TypeVariable('a')}
+	return hm.NewFnType(t, t)
+}
+
+// InferShape implements gorgonia.Op.
+func (op *augmentOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return op.outShape.Clone(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *augmentOp) Do(inp ...G.Value) (G.Value, error) {
+	x := inp[0].(T.Tensor)
+	op.last = op.sample(op.rng, op.inShape, op.training)
+	out := T.New(T.WithShape(op.outShape.Clone()...), T.Of(x.Dtype()))
+	switch x.Dtype() {
+	case T.Float64:
+		augmentLoop(op.last, x.Data().([]float64), out.Data().([]float64), false)
+	case T.Float32:
+		augmentLoop(op.last, x.Data().([]float32), out.Data().([]float32), false)
+	default:
+		return nil, fmt.Errorf("%s can only be used on float64 and float32", op.name)
+	}
+	return out, nil
+}
+
+// augmentLoop applies the augmentation a, going from in to out.
+// If backwards is true, in is the output grad and out is the input grad, so the copies go the other way and the noise is ignored.
+// out must start as all zeros.
+func augmentLoop[F float32 | float64](a augmentation, in, out []F, backwards bool) {
+	size := len(out)
+	if backwards {
+		size = len(in)
+	}
+	for i := 0; i < size; i++ {
+		src := i
+		if a.index != nil {
+			src = a.index[i]
+		}
+		if src < 0 {
+			continue
+		}
+		scale := F(1)
+		if a.scale != nil {
+			scale = F(a.scale[i])
+		}
+		if backwards {
+			out[src] += in[i] * scale
+		} else {
+			out[i] = in[src] * scale
+		}
+	}
+	if !backwards && a.noise != nil {
+		for i := range out {
+			out[i] += F(a.noise[i])
+		}
+	}
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*augmentOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*augmentOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*augmentOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *augmentOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *augmentOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *augmentOp) String() string {
+	return fmt.Sprintf("AugmentOp{%s,in=%v,out=%v}", op.name, op.inShape, op.outShape)
+}
+
+// DiffWRT implements gorgonia.SDOp.
+func (*augmentOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+// SymDiff implements gorgonia.SDOp.
+func (op *augmentOp) SymDiff(inputs G.Nodes, output *G.Node, grad *G.Node) (G.Nodes, error) {
+	dx, err := G.ApplyOp(&augmentDiffOp{op}, grad)
+	if err != nil {
+		return nil, err
+	}
+	return G.Nodes{dx}, nil
+}
+
+var _ G.Op = &augmentDiffOp{}
+
+// augmentDiffOp calculates the gradient of an augmentOp wrt its input, using the augmentation from the last forward pass.
+// It takes the output grad as its only input.
+type augmentDiffOp struct {
+	fwd *augmentOp
+}
+
+// Arity implements gorgonia.Op.
+func (*augmentDiffOp) Arity() int { return 1 }
+
+// Type implements gorgonia.Op.
+func (op *augmentDiffOp) Type() hm.Type {
+	t := G.TensorType{Dims: op.fwd.inShape.Dims(), Of: hm.TypeVariable('a')}
+	return hm.NewFnType(t, t)
+}
+
+// InferShape implements gorgonia.Op.
+func (op *augmentDiffOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return op.fwd.inShape.Clone(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *augmentDiffOp) Do(inp ...G.Value) (G.Value, error) {
+	dy := inp[0].(T.Tensor)
+	out := T.New(T.WithShape(op.fwd.inShape.Clone()...), T.Of(dy.Dtype()))
+	switch dy.Dtype() {
+	case T.Float64:
+		augmentLoop(op.fwd.last, dy.Data().([]float64), out.Data().([]float64), true)
+	case T.Float32:
+		augmentLoop(op.fwd.last, dy.Data().([]float32), out.Data().([]float32), true)
+	default:
+		return nil, fmt.Errorf("%s can only be used on float64 and float32", op.fwd.name)
+	}
+	return out, nil
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*augmentDiffOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*augmentDiffOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*augmentDiffOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *augmentDiffOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *augmentDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *augmentDiffOp) String() string {
+	return fmt.Sprintf("AugmentDiffOp{%s,in=%v,out=%v}", op.fwd.name, op.fwd.inShape, op.fwd.outShape)
+}
+
+// imageIndex makes the index of an augmentation that moves pixels around in a batch of images with shape (batch, channels, height, width), producing images of size (outH, outW).
+// src is called for each image in the batch, and returns a function that gives the input pixel for each output pixel (which can be outside the image, in which case the output is zero).
+func imageIndex(inShape T.Shape, outH, outW int, src func(b int) func(y, x int) (int, int)) []int {
+	batchSize, channels, inH, inW := inShape[0], inShape[1], inShape[2], inShape[3]
+	index := make([]int, batchSize*channels*outH*outW)
+	i := 0
+	for b := 0; b < batchSize; b++ {
+		srcFn := src(b)
+		for c := 0; c < channels; c++ {
+			for y := 0; y < outH; y++ {
+				for x := 0; x < outW; x++ {
+					sy, sx := srcFn(y, x)
+					if sy < 0 || sy >= inH || sx < 0 || sx >= inW {
+						index[i] = -1
+					} else {
+						index[i] = ((b*channels+c)*inH+sy)*inW + sx
+					}
+					i++
+				}
+			}
+		}
+	}
+	return index
+}
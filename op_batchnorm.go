@@ -0,0 +1,249 @@
+package goras
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"math"
+
+	"github.com/chewxy/hm"
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+var _ G.Op = &batchNormOp{}
+var _ G.SDOp = &batchNormOp{}
+var _ G.TrainModeOp = &batchNormOp{}
+
+// batchNormOp normalises its input over every axis apart from axis 1 (the channel axis).
+// It does not scale or shift the output, the layer does that with normal gorgonia ops so we get those gradients for free.
+// When training, it uses the batch statistics and updates the running statistics.
+// When not training, it just uses the running statistics.
+// I have written this instead of using G.BatchNorm because that one resets its running statistics every time it is put into training mode.
+type batchNormOp struct {
+	name        string
+	dims        int
+	momentum    float64
+	epsilon     float64
+	runningMean *T.Dense
+	runningVar  *T.Dense
+	training    bool
+	// These are cached by the forward pass, and used by the backward pass
+	lastMean     []float64
+	lastInvStd   []float64
+	lastTraining bool
+}
+
+// SetTraining implements gorgonia.TrainModeOp.
+func (op *batchNormOp) SetTraining(isTraining bool) error {
+	op.training = isTraining
+	return nil
+}
+
+// Arity implements gorgonia.Op.
+func (*batchNormOp) Arity() int { return 1 }
+
+// Type implements gorgonia.Op.
+func (op *batchNormOp) Type() hm.Type {
+	t := G.TensorType{Dims: op.dims, Of: hm.TypeVariable('a')}
+	return hm.NewFnType(t, t)
+}
+
+// InferShape implements gorgonia.Op.
+func (*batchNormOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return inputs[0].(T.Shape).Clone(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *batchNormOp) Do(inp ...G.Value) (G.Value, error) {
+	x := inp[0].(T.Tensor)
+	out := T.New(T.WithShape(x.Shape().Clone()...), T.Of(x.Dtype()))
+	var err error
+	switch x.Dtype() {
+	case T.Float64:
+		err = batchNormForward(op, x.Data().([]float64), out.Data().([]float64), x.Shape())
+	case T.Float32:
+		err = batchNormForward(op, x.Data().([]float32), out.Data().([]float32), x.Shape())
+	default:
+		err = fmt.Errorf("batchnorm can only be used on float64 and float32")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*batchNormOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*batchNormOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*batchNormOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *batchNormOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *batchNormOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *batchNormOp) String() string {
+	return fmt.Sprintf("BatchNormOp{%s}", op.name)
+}
+
+// DiffWRT implements gorgonia.SDOp.
+func (*batchNormOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+// SymDiff implements gorgonia.SDOp.
+func (op *batchNormOp) SymDiff(inputs G.Nodes, output *G.Node, grad *G.Node) (G.Nodes, error) {
+	dx, err := G.ApplyOp(&batchNormDiffOp{op}, inputs[0], grad)
+	if err != nil {
+		return nil, err
+	}
+	return G.Nodes{dx}, nil
+}
+
+func batchNormForward[F float32 | float64](op *batchNormOp, x, out []F, shape T.Shape) error {
+	batchSize, channels := shape[0], shape[1]
+	spatial := shape.TotalSize() / (batchSize * channels)
+	n := float64(batchSize * spatial)
+	runningMean, runningVar := op.runningMean.Data().([]F), op.runningVar.Data().([]F)
+	if len(runningMean) != channels || len(runningVar) != channels {
+		return fmt.Errorf("batchnorm expected %v channels but got %v", len(runningMean), channels)
+	}
+	op.lastMean = make([]float64, channels)
+	op.lastInvStd = make([]float64, channels)
+	op.lastTraining = op.training
+	for c := 0; c < channels; c++ {
+		var mean, variance float64
+		if op.training {
+			for b := 0; b < batchSize; b++ {
+				for s := 0; s < spatial; s++ {
+					mean += float64(x[(b*channels+c)*spatial+s])
+				}
+			}
+			mean /= n
+			for b := 0; b < batchSize; b++ {
+				for s := 0; s < spatial; s++ {
+					d := float64(x[(b*channels+c)*spatial+s]) - mean
+					variance += d * d
+				}
+			}
+			variance /= n
+			runningMean[c] = F(op.momentum*float64(runningMean[c]) + (1-op.momentum)*mean)
+			runningVar[c] = F(op.momentum*float64(runningVar[c]) + (1-op.momentum)*variance)
+		} else {
+			mean, variance = float64(runningMean[c]), float64(runningVar[c])
+		}
+		invStd := 1 / math.Sqrt(variance+op.epsilon)
+		for b := 0; b < batchSize; b++ {
+			for s := 0; s < spatial; s++ {
+				i := (b*channels+c)*spatial + s
+				out[i] = F((float64(x[i]) - mean) * invStd)
+			}
+		}
+		op.lastMean[c], op.lastInvStd[c] = mean, invStd
+	}
+	return nil
+}
+
+var _ G.Op = &batchNormDiffOp{}
+
+// batchNormDiffOp calculates the gradient of a batchNormOp wrt its input.
+// It takes (x, outputGrad) as inputs, and uses the statistics cached in the forward op.
+type batchNormDiffOp struct {
+	fwd *batchNormOp
+}
+
+// Arity implements gorgonia.Op.
+func (*batchNormDiffOp) Arity() int { return 2 }
+
+// Type implements gorgonia.Op.
+func (op *batchNormDiffOp) Type() hm.Type {
+	t := G.TensorType{Dims: op.fwd.dims, Of: hm.TypeVariable('a')}
+	return hm.NewFnType(t, t, t)
+}
+
+// InferShape implements gorgonia.Op.
+func (*batchNormDiffOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return inputs[0].(T.Shape).Clone(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *batchNormDiffOp) Do(inp ...G.Value) (G.Value, error) {
+	x, dy := inp[0].(T.Tensor), inp[1].(T.Tensor)
+	dx := T.New(T.WithShape(x.Shape().Clone()...), T.Of(x.Dtype()))
+	switch x.Dtype() {
+	case T.Float64:
+		batchNormBackward(op.fwd, x.Data().([]float64), dy.Data().([]float64), dx.Data().([]float64), x.Shape())
+	case T.Float32:
+		batchNormBackward(op.fwd, x.Data().([]float32), dy.Data().([]float32), dx.Data().([]float32), x.Shape())
+	default:
+		return nil, fmt.Errorf("batchnorm can only be used on float64 and float32")
+	}
+	return dx, nil
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*batchNormDiffOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*batchNormDiffOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*batchNormDiffOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *batchNormDiffOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *batchNormDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *batchNormDiffOp) String() string {
+	return fmt.Sprintf("BatchNormDiffOp{%s}", op.fwd.name)
+}
+
+func batchNormBackward[F float32 | float64](op *batchNormOp, x, dy, dx []F, shape T.Shape) {
+	batchSize, channels := shape[0], shape[1]
+	spatial := shape.TotalSize() / (batchSize * channels)
+	n := float64(batchSize * spatial)
+	for c := 0; c < channels; c++ {
+		mean, invStd := op.lastMean[c], op.lastInvStd[c]
+		if !op.lastTraining {
+			// The statistics were constants, so this is just a scale
+			for b := 0; b < batchSize; b++ {
+				for s := 0; s < spatial; s++ {
+					i := (b*channels+c)*spatial + s
+					dx[i] = F(float64(dy[i]) * invStd)
+				}
+			}
+			continue
+		}
+		var sumDy, sumDyXHat float64
+		for b := 0; b < batchSize; b++ {
+			for s := 0; s < spatial; s++ {
+				i := (b*channels+c)*spatial + s
+				sumDy += float64(dy[i])
+				sumDyXHat += float64(dy[i]) * (float64(x[i]) - mean) * invStd
+			}
+		}
+		for b := 0; b < batchSize; b++ {
+			for s := 0; s < spatial; s++ {
+				i := (b*channels+c)*spatial + s
+				xHat := (float64(x[i]) - mean) * invStd
+				dx[i] = F(invStd / n * (n*float64(dy[i]) - sumDy - xHat*sumDyXHat))
+			}
+		}
+	}
+}
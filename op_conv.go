@@ -0,0 +1,264 @@
+package goras
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+
+	"github.com/chewxy/hm"
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+var _ G.Op = &convNDOp{}
+var _ G.SDOp = &convNDOp{}
+
+// convNDOp is a convolution (well, a cross-correlation like G.Conv2d) with any number of spatial dims.
+// It takes (x, kernels) as inputs, where x is (batch, in_channels, ...spatial) and kernels is (out_channels, in_channels, ...kernel_size).
+// The padding is added to both sides of each spatial dim, and the kernel positions are spaced out by the dilation.
+// The channels can be split into groups, where each output channel only sees the input channels in its group, so kernels is (out_channels, in_channels/groups, ...kernel_size).
+// A depthwise convolution is one with a group for every input channel.
+// This is used for Conv1D, Conv3D and the depthwise convolutions, as gorgonia only has normal 2D convolutions.
+type convNDOp struct {
+	inShape     T.Shape
+	kernelShape T.Shape
+	stride      []int
+	pad         []int
+	dilation    []int
+	groups      int
+	// inIndex is the result of windowIndices for this op.
+	inIndex []int
+}
+
+// newConvNDOp creates a convNDOp, working out which input position lines up with each output and kernel position.
+func newConvNDOp(inShape, kernelShape T.Shape, stride, pad, dilation []int, groups int) (*convNDOp, error) {
+	if groups < 1 || inShape[1]%groups != 0 || kernelShape[0]%groups != 0 {
+		return nil, fmt.Errorf("cannot split %v input channels and %v output channels into %v groups", inShape[1], kernelShape[0], groups)
+	}
+	if kernelShape[1] != inShape[1]/groups {
+		return nil, fmt.Errorf("kernel %v should have %v input channels for an input %v with %v groups", kernelShape, inShape[1]/groups, inShape, groups)
+	}
+	op := &convNDOp{inShape.Clone(), kernelShape.Clone(), stride, pad, dilation, groups, nil}
+	spatialIn, spatialKernel, spatialOut := inShape[2:], kernelShape[2:], op.outShape()[2:]
+	for i := range spatialOut {
+		if spatialOut[i] < 1 {
+			return nil, fmt.Errorf("kernel %v with dilation %v is too big for the input %v with padding %v", kernelShape, dilation, inShape, pad)
+		}
+	}
+	op.inIndex = windowIndices(spatialIn, spatialKernel, spatialOut, stride, dilation, pad)
+	return op, nil
+}
+
+// windowIndices works out which input position is at each position of the window for each output position of a sliding window op (e.g. convolution or pooling).
+// The result has one entry for each pair of output position o and window position w, at o*numWindowPositions + w.
+// The window positions are spaced out by the dilation (1 means no gaps).
+// The entries are flat spatial indices into the input, or -1 if that position is in the padding.
+func windowIndices(spatialIn, spatialWindow, spatialOut T.Shape, stride, dilation, padBefore []int) []int {
+	numOut, numWindow := spatialOut.TotalSize(), spatialWindow.TotalSize()
+	indices := make([]int, numOut*numWindow)
+	for o := 0; o < numOut; o++ {
+		outCoords := unravelIndex(o, spatialOut)
+		for w := 0; w < numWindow; w++ {
+			windowCoords := unravelIndex(w, spatialWindow)
+			inFlat := 0
+			for d := range spatialIn {
+				c := outCoords[d]*stride[d] + windowCoords[d]*dilation[d] - padBefore[d]
+				if c < 0 || c >= spatialIn[d] {
+					inFlat = -1
+					break
+				}
+				inFlat = inFlat*spatialIn[d] + c
+			}
+			indices[o*numWindow+w] = inFlat
+		}
+	}
+	return indices
+}
+
+// unravelIndex converts a flat index into coordinates for the given shape.
+func unravelIndex(i int, shape T.Shape) []int {
+	coords := make([]int, len(shape))
+	for d := len(shape) - 1; d >= 0; d-- {
+		coords[d] = i % shape[d]
+		i /= shape[d]
+	}
+	return coords
+}
+
+func (op *convNDOp) outShape() T.Shape {
+	s := T.Shape{op.inShape[0], op.kernelShape[0]}
+	for d := 2; d < len(op.inShape); d++ {
+		dilatedKernel := op.dilation[d-2]*(op.kernelShape[d]-1) + 1
+		s = append(s, (op.inShape[d]+2*op.pad[d-2]-dilatedKernel)/op.stride[d-2]+1)
+	}
+	return s
+}
+
+// Arity implements gorgonia.Op.
+func (*convNDOp) Arity() int { return 2 }
+
+// Type implements gorgonia.Op.
+func (op *convNDOp) Type() hm.Type {
+	t := G.TensorType{Dims: op.inShape.Dims(), Of: hm.TypeVariable('a')}
+	return hm.NewFnType(t, t, t)
+}
+
+// InferShape implements gorgonia.Op.
+func (op *convNDOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return op.outShape(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *convNDOp) Do(inp ...G.Value) (G.Value, error) {
+	x, k := inp[0].(T.Tensor), inp[1].(T.Tensor)
+	out := T.New(T.WithShape(op.outShape()...), T.Of(x.Dtype()))
+	switch x.Dtype() {
+	case T.Float64:
+		convNDLoop(op, x.Data().([]float64), k.Data().([]float64), out.Data().([]float64), 0)
+	case T.Float32:
+		convNDLoop(op, x.Data().([]float32), k.Data().([]float32), out.Data().([]float32), 0)
+	default:
+		return nil, fmt.Errorf("convolution can only be used on float64 and float32")
+	}
+	return out, nil
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*convNDOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*convNDOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*convNDOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *convNDOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *convNDOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *convNDOp) String() string {
+	return fmt.Sprintf("ConvNDOp{in=%v,kernel=%v,stride=%v,pad=%v,dilation=%v,groups=%v}", op.inShape, op.kernelShape, op.stride, op.pad, op.dilation, op.groups)
+}
+
+// DiffWRT implements gorgonia.SDOp.
+func (*convNDOp) DiffWRT(inputs int) []bool { return []bool{true, true} }
+
+// SymDiff implements gorgonia.SDOp.
+func (op *convNDOp) SymDiff(inputs G.Nodes, output *G.Node, grad *G.Node) (G.Nodes, error) {
+	dx, err := G.ApplyOp(&convNDDiffOp{op, 1}, inputs[0], inputs[1], grad)
+	if err != nil {
+		return nil, err
+	}
+	dk, err := G.ApplyOp(&convNDDiffOp{op, 2}, inputs[0], inputs[1], grad)
+	if err != nil {
+		return nil, err
+	}
+	return G.Nodes{dx, dk}, nil
+}
+
+// convNDLoop runs over every pair of output position and kernel position, for each output channel and the input channels in its group.
+//   - mode 0: out += x * k (forward pass, out is the output)
+//   - mode 1: out += dy * k (grad wrt x, out has the shape of x and y is the output grad)
+//   - mode 2: out += x * dy (grad wrt k, out has the shape of k and y is the output grad)
+func convNDLoop[F float32 | float64](op *convNDOp, x, k, out []F, mode int, y ...[]F) {
+	batchSize, inChannels, outChannels := op.inShape[0], op.inShape[1], op.kernelShape[0]
+	inPerGroup, outPerGroup := inChannels/op.groups, outChannels/op.groups
+	numIn, numKernel := op.inShape[2:].TotalSize(), op.kernelShape[2:].TotalSize()
+	numOut := len(op.inIndex) / numKernel
+	for b := 0; b < batchSize; b++ {
+		for o := 0; o < outChannels; o++ {
+			group := o / outPerGroup
+			for gc := 0; gc < inPerGroup; gc++ {
+				c := group*inPerGroup + gc
+				xOffset := (b*inChannels + c) * numIn
+				kOffset := (o*inPerGroup + gc) * numKernel
+				yOffset := (b*outChannels + o) * numOut
+				for p := 0; p < numOut; p++ {
+					for kp := 0; kp < numKernel; kp++ {
+						ip := op.inIndex[p*numKernel+kp]
+						if ip < 0 {
+							continue
+						}
+						switch mode {
+						case 0:
+							out[yOffset+p] += x[xOffset+ip] * k[kOffset+kp]
+						case 1:
+							out[xOffset+ip] += y[0][yOffset+p] * k[kOffset+kp]
+						case 2:
+							out[kOffset+kp] += x[xOffset+ip] * y[0][yOffset+p]
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+var _ G.Op = &convNDDiffOp{}
+
+// convNDDiffOp calculates the gradient of a convNDOp wrt one of its inputs.
+// It takes (x, kernels, outputGrad) as inputs. wrt is 1 for the grad of x and 2 for the grad of kernels.
+type convNDDiffOp struct {
+	fwd *convNDOp
+	wrt int
+}
+
+// Arity implements gorgonia.Op.
+func (*convNDDiffOp) Arity() int { return 3 }
+
+// Type implements gorgonia.Op.
+func (op *convNDDiffOp) Type() hm.Type {
+	t := G.TensorType{Dims: op.fwd.inShape.Dims(), Of: hm.TypeVariable('a')}
+	return hm.NewFnType(t, t, t, t)
+}
+
+// InferShape implements gorgonia.Op.
+func (op *convNDDiffOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return inputs[op.wrt-1].(T.Shape).Clone(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *convNDDiffOp) Do(inp ...G.Value) (G.Value, error) {
+	x, k, dy := inp[0].(T.Tensor), inp[1].(T.Tensor), inp[2].(T.Tensor)
+	out := T.New(T.WithShape(inp[op.wrt-1].Shape().Clone()...), T.Of(x.Dtype()))
+	switch x.Dtype() {
+	case T.Float64:
+		convNDLoop(op.fwd, x.Data().([]float64), k.Data().([]float64), out.Data().([]float64), op.wrt, dy.Data().([]float64))
+	case T.Float32:
+		convNDLoop(op.fwd, x.Data().([]float32), k.Data().([]float32), out.Data().([]float32), op.wrt, dy.Data().([]float32))
+	default:
+		return nil, fmt.Errorf("convolution can only be used on float64 and float32")
+	}
+	return out, nil
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*convNDDiffOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*convNDDiffOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*convNDDiffOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *convNDDiffOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *convNDDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *convNDDiffOp) String() string {
+	return fmt.Sprintf("ConvNDDiffOp{wrt=%v,in=%v,kernel=%v,stride=%v,pad=%v,dilation=%v,groups=%v}", op.wrt, op.fwd.inShape, op.fwd.kernelShape, op.fwd.stride, op.fwd.pad, op.fwd.dilation, op.fwd.groups)
+}
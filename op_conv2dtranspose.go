@@ -0,0 +1,198 @@
+package goras
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+
+	"github.com/chewxy/hm"
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+var _ G.Op = &conv2DTransposeOp{}
+var _ G.SDOp = &conv2DTransposeOp{}
+
+// conv2DTransposeOp performs a transposed convolution (sometimes called a deconvolution).
+// It takes (x, kernels) as inputs, where x is (batch, in_channels, h, w) and kernels is (in_channels, out_channels, kernel_h, kernel_w).
+// Every input pixel adds a copy of the kernel scaled by its value to the output, with the copies placed stride pixels apart.
+// The output is then cropped by padBefore at the top/left, and to outShape at the bottom/right.
+type conv2DTransposeOp struct {
+	stride    []int
+	padBefore []int
+	outShape  T.Shape
+}
+
+// Arity implements gorgonia.Op.
+func (*conv2DTransposeOp) Arity() int { return 2 }
+
+// Type implements gorgonia.Op.
+func (*conv2DTransposeOp) Type() hm.Type {
+	t := G.TensorType{Dims: 4, Of: hm.TypeVariable('a')}
+	return hm.NewFnType(t, t, t)
+}
+
+// InferShape implements gorgonia.Op.
+func (op *conv2DTransposeOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return op.outShape.Clone(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *conv2DTransposeOp) Do(inp ...G.Value) (G.Value, error) {
+	x, k := inp[0].(T.Tensor), inp[1].(T.Tensor)
+	out := T.New(T.WithShape(op.outShape.Clone()...), T.Of(x.Dtype()))
+	switch x.Dtype() {
+	case T.Float64:
+		conv2DTransposeLoop(op, x.Shape(), k.Shape(), x.Data().([]float64), k.Data().([]float64), out.Data().([]float64), 0)
+	case T.Float32:
+		conv2DTransposeLoop(op, x.Shape(), k.Shape(), x.Data().([]float32), k.Data().([]float32), out.Data().([]float32), 0)
+	default:
+		return nil, fmt.Errorf("conv2d transpose can only be used on float64 and float32")
+	}
+	return out, nil
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*conv2DTransposeOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*conv2DTransposeOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*conv2DTransposeOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *conv2DTransposeOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *conv2DTransposeOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *conv2DTransposeOp) String() string {
+	return fmt.Sprintf("Conv2DTransposeOp{stride=%v,pad=%v,out=%v}", op.stride, op.padBefore, op.outShape)
+}
+
+// DiffWRT implements gorgonia.SDOp.
+func (*conv2DTransposeOp) DiffWRT(inputs int) []bool { return []bool{true, true} }
+
+// SymDiff implements gorgonia.SDOp.
+func (op *conv2DTransposeOp) SymDiff(inputs G.Nodes, output *G.Node, grad *G.Node) (G.Nodes, error) {
+	dx, err := G.ApplyOp(&conv2DTransposeDiffOp{op, 1}, inputs[0], inputs[1], grad)
+	if err != nil {
+		return nil, err
+	}
+	dk, err := G.ApplyOp(&conv2DTransposeDiffOp{op, 2}, inputs[0], inputs[1], grad)
+	if err != nil {
+		return nil, err
+	}
+	return G.Nodes{dx, dk}, nil
+}
+
+// conv2DTransposeLoop runs over every pair of input pixel and kernel pixel that affects the output.
+//   - mode 0: out += x * k (forward pass, out is the output)
+//   - mode 1: out += dy * k (grad wrt x, out has the shape of x and y is the output grad)
+//   - mode 2: out += x * dy (grad wrt k, out has the shape of k and y is the output grad)
+func conv2DTransposeLoop[F float32 | float64](op *conv2DTransposeOp, xShape, kShape T.Shape, x, k, out []F, mode int, y ...[]F) {
+	batchSize, inChannels, inH, inW := xShape[0], xShape[1], xShape[2], xShape[3]
+	outChannels, kH, kW := kShape[1], kShape[2], kShape[3]
+	outH, outW := op.outShape[2], op.outShape[3]
+	for b := 0; b < batchSize; b++ {
+		for c := 0; c < inChannels; c++ {
+			for i := 0; i < inH; i++ {
+				for j := 0; j < inW; j++ {
+					xi := ((b*inChannels+c)*inH+i)*inW + j
+					for o := 0; o < outChannels; o++ {
+						for ki := 0; ki < kH; ki++ {
+							p := i*op.stride[0] + ki - op.padBefore[0]
+							if p < 0 || p >= outH {
+								continue
+							}
+							for kj := 0; kj < kW; kj++ {
+								q := j*op.stride[1] + kj - op.padBefore[1]
+								if q < 0 || q >= outW {
+									continue
+								}
+								kIdx := ((c*outChannels+o)*kH+ki)*kW + kj
+								yi := ((b*outChannels+o)*outH+p)*outW + q
+								switch mode {
+								case 0:
+									out[yi] += x[xi] * k[kIdx]
+								case 1:
+									out[xi] += y[0][yi] * k[kIdx]
+								case 2:
+									out[kIdx] += x[xi] * y[0][yi]
+								}
+							}
+						}
+					}
+				}
+			}
+		}
+	}
+}
+
+var _ G.Op = &conv2DTransposeDiffOp{}
+
+// conv2DTransposeDiffOp calculates the gradient of a conv2DTransposeOp wrt one of its inputs.
+// It takes (x, kernels, outputGrad) as inputs. wrt is 1 for the grad of x and 2 for the grad of kernels.
+type conv2DTransposeDiffOp struct {
+	fwd *conv2DTransposeOp
+	wrt int
+}
+
+// Arity implements gorgonia.Op.
+func (*conv2DTransposeDiffOp) Arity() int { return 3 }
+
+// Type implements gorgonia.Op.
+func (*conv2DTransposeDiffOp) Type() hm.Type {
+	t := G.TensorType{Dims: 4, Of: hm.TypeVariable('a')}
+	return hm.NewFnType(t, t, t, t)
+}
+
+// InferShape implements gorgonia.Op.
+func (op *conv2DTransposeDiffOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return inputs[op.wrt-1].(T.Shape).Clone(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *conv2DTransposeDiffOp) Do(inp ...G.Value) (G.Value, error) {
+	x, k, dy := inp[0].(T.Tensor), inp[1].(T.Tensor), inp[2].(T.Tensor)
+	out := T.New(T.WithShape(inp[op.wrt-1].Shape().Clone()...), T.Of(x.Dtype()))
+	switch x.Dtype() {
+	case T.Float64:
+		conv2DTransposeLoop(op.fwd, x.Shape(), k.Shape(), x.Data().([]float64), k.Data().([]float64), out.Data().([]float64), op.wrt, dy.Data().([]float64))
+	case T.Float32:
+		conv2DTransposeLoop(op.fwd, x.Shape(), k.Shape(), x.Data().([]float32), k.Data().([]float32), out.Data().([]float32), op.wrt, dy.Data().([]float32))
+	default:
+		return nil, fmt.Errorf("conv2d transpose can only be used on float64 and float32")
+	}
+	return out, nil
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*conv2DTransposeDiffOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*conv2DTransposeDiffOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*conv2DTransposeDiffOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *conv2DTransposeDiffOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *conv2DTransposeDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *conv2DTransposeDiffOp) String() string {
+	return fmt.Sprintf("Conv2DTransposeDiffOp{wrt=%v,stride=%v,pad=%v,out=%v}", op.wrt, op.fwd.stride, op.fwd.padBefore, op.fwd.outShape)
+}
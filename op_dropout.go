@@ -0,0 +1,186 @@
+package goras
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"math/rand"
+	"time"
+
+	"github.com/chewxy/hm"
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+var _ G.Op = &dropoutOp{}
+var _ G.SDOp = &dropoutOp{}
+var _ G.TrainModeOp = &dropoutOp{}
+
+// dropoutOp randomly sets values to zero with the given probability while training, and scales the rest up to keep the same mean.
+// When not training, it just copies its input.
+// I have written this instead of using G.Dropout because that one does not remember which values it dropped, so the gradient is wrong.
+type dropoutOp struct {
+	name        string
+	probability float64
+	training    bool
+	rng         *rand.Rand
+	// This is cached by the forward pass, and used by the backward pass. It is nil if nothing was dropped.
+	lastMask []float64
+}
+
+// newDropoutOp creates a dropout op that is in training mode.
+func newDropoutOp(name string, probability float64) *dropoutOp {
+	return &dropoutOp{
+		name:        name,
+		probability: probability,
+		training:    true,
+		rng:         rand.New(rand.NewSource(time.Now().UnixNano())),
+	}
+}
+
+// SetTraining implements gorgonia.TrainModeOp.
+func (op *dropoutOp) SetTraining(isTraining bool) error {
+	op.training = isTraining
+	return nil
+}
+
+// Arity implements gorgonia.Op.
+func (*dropoutOp) Arity() int { return 1 }
+
+// Type implements gorgonia.Op.
+func (*dropoutOp) Type() hm.Type {
+	a := hm.TypeVariable('a')
+	return hm.NewFnType(a, a)
+}
+
+// InferShape implements gorgonia.Op.
+func (*dropoutOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return inputs[0].(T.Shape).Clone(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *dropoutOp) Do(inp ...G.Value) (G.Value, error) {
+	x := inp[0].(T.Tensor)
+	op.lastMask = nil
+	if op.training && op.probability > 0 {
+		op.lastMask = make([]float64, x.Shape().TotalSize())
+		keep := 1 - op.probability
+		for i := range op.lastMask {
+			if op.rng.Float64() < keep {
+				op.lastMask[i] = 1 / keep
+			}
+		}
+	}
+	return applyMask(x, op.lastMask)
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*dropoutOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*dropoutOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*dropoutOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *dropoutOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *dropoutOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *dropoutOp) String() string {
+	return fmt.Sprintf("DropoutOp{%s,p=%v}", op.name, op.probability)
+}
+
+// DiffWRT implements gorgonia.SDOp.
+func (*dropoutOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+// SymDiff implements gorgonia.SDOp.
+func (op *dropoutOp) SymDiff(inputs G.Nodes, output *G.Node, grad *G.Node) (G.Nodes, error) {
+	dx, err := G.ApplyOp(&dropoutDiffOp{op}, grad)
+	if err != nil {
+		return nil, err
+	}
+	return G.Nodes{dx}, nil
+}
+
+// applyMask returns a copy of x multiplied elementwise by mask. If the mask is nil, it just returns a copy of x.
+func applyMask(x T.Tensor, mask []float64) (G.Value, error) {
+	out := T.New(T.WithShape(x.Shape().Clone()...), T.Of(x.Dtype()))
+	switch x.Dtype() {
+	case T.Float64:
+		applyMaskT(x.Data().([]float64), out.Data().([]float64), mask)
+	case T.Float32:
+		applyMaskT(x.Data().([]float32), out.Data().([]float32), mask)
+	default:
+		return nil, fmt.Errorf("dropout can only be used on float64 and float32")
+	}
+	return out, nil
+}
+
+func applyMaskT[F float32 | float64](x, out []F, mask []float64) {
+	if mask == nil {
+		copy(out, x)
+		return
+	}
+	for i := range x {
+		out[i] = x[i] * F(mask[i])
+	}
+}
+
+var _ G.Op = &dropoutDiffOp{}
+
+// dropoutDiffOp calculates the gradient of a dropoutOp wrt its input, using the mask from the last forward pass.
+// It takes the output grad as its only input.
+type dropoutDiffOp struct {
+	fwd *dropoutOp
+}
+
+// Arity implements gorgonia.Op.
+func (*dropoutDiffOp) Arity() int { return 1 }
+
+// Type implements gorgonia.Op.
+func (*dropoutDiffOp) Type() hm.Type {
+	a := hm.TypeVariable('a')
+	return hm.NewFnType(a, a)
+}
+
+// InferShape implements gorgonia.Op.
+func (*dropoutDiffOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return inputs[0].(T.Shape).Clone(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *dropoutDiffOp) Do(inp ...G.Value) (G.Value, error) {
+	return applyMask(inp[0].(T.Tensor), op.fwd.lastMask)
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*dropoutDiffOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*dropoutDiffOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*dropoutDiffOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *dropoutDiffOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *dropoutDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *dropoutDiffOp) String() string {
+	return fmt.Sprintf("DropoutDiffOp{%s,p=%v}", op.fwd.name, op.fwd.probability)
+}
@@ -0,0 +1,189 @@
+package goras
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+
+	"github.com/chewxy/hm"
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+var _ G.Op = &embeddingOp{}
+var _ G.SDOp = &embeddingOp{}
+
+// embeddingOp looks up rows of an embedding table.
+// It takes (table, indices) as inputs, where table is (vocab_size, dim) and indices is an int tensor of any shape.
+// The output has the shape of indices with dim appended to the end.
+// If paddingIndex is not negative, that index always produces a row of zeros and that row of the table never receives a gradient.
+type embeddingOp struct {
+	name         string
+	indexDims    int
+	paddingIndex int
+}
+
+// Arity implements gorgonia.Op.
+func (*embeddingOp) Arity() int { return 2 }
+
+// Type implements gorgonia.Op.
+func (op *embeddingOp) Type() hm.Type {
+	a := hm.TypeVariable('a')
+	return hm.NewFnType(G.TensorType{Dims: 2, Of: a}, G.TensorType{Dims: op.indexDims, Of: T.Int}, G.TensorType{Dims: op.indexDims + 1, Of: a})
+}
+
+// InferShape implements gorgonia.Op.
+func (*embeddingOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	table, indices := inputs[0].(T.Shape), inputs[1].(T.Shape)
+	return append(indices.Clone(), table[1]), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *embeddingOp) Do(inp ...G.Value) (G.Value, error) {
+	table, indices := inp[0].(T.Tensor), inp[1].(T.Tensor)
+	idxs, ok := indices.Data().([]int)
+	if !ok {
+		return nil, fmt.Errorf("embedding indices must be ints")
+	}
+	vocabSize, dim := table.Shape()[0], table.Shape()[1]
+	out := T.New(T.WithShape(append(indices.Shape().Clone(), dim)...), T.Of(table.Dtype()))
+	var err error
+	switch table.Dtype() {
+	case T.Float64:
+		err = embeddingForward(table.Data().([]float64), idxs, out.Data().([]float64), vocabSize, dim, op.paddingIndex)
+	case T.Float32:
+		err = embeddingForward(table.Data().([]float32), idxs, out.Data().([]float32), vocabSize, dim, op.paddingIndex)
+	default:
+		err = fmt.Errorf("embedding can only be used on float64 and float32")
+	}
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*embeddingOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*embeddingOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*embeddingOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *embeddingOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *embeddingOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *embeddingOp) String() string {
+	return fmt.Sprintf("EmbeddingOp{%s}", op.name)
+}
+
+// DiffWRT implements gorgonia.SDOp.
+func (*embeddingOp) DiffWRT(inputs int) []bool { return []bool{true, false} }
+
+// SymDiff implements gorgonia.SDOp.
+func (op *embeddingOp) SymDiff(inputs G.Nodes, output *G.Node, grad *G.Node) (G.Nodes, error) {
+	dTable, err := G.ApplyOp(&embeddingDiffOp{op}, inputs[0], inputs[1], grad)
+	if err != nil {
+		return nil, err
+	}
+	// The indices are not differentiable
+	return G.Nodes{dTable, nil}, nil
+}
+
+func embeddingForward[F float32 | float64](table []F, indices []int, out []F, vocabSize, dim, paddingIndex int) error {
+	for i, idx := range indices {
+		if idx < 0 || idx >= vocabSize {
+			return fmt.Errorf("embedding index %v is out of range for vocab size %v", idx, vocabSize)
+		}
+		if idx == paddingIndex {
+			// The output is already zeroed
+			continue
+		}
+		copy(out[i*dim:(i+1)*dim], table[idx*dim:(idx+1)*dim])
+	}
+	return nil
+}
+
+var _ G.Op = &embeddingDiffOp{}
+
+// embeddingDiffOp calculates the gradient of an embeddingOp wrt its table.
+// It takes (table, indices, outputGrad) as inputs, and adds up the gradients of every lookup of each row.
+type embeddingDiffOp struct {
+	fwd *embeddingOp
+}
+
+// Arity implements gorgonia.Op.
+func (*embeddingDiffOp) Arity() int { return 3 }
+
+// Type implements gorgonia.Op.
+func (op *embeddingDiffOp) Type() hm.Type {
+	a := hm.TypeVariable('a')
+	t := G.TensorType{Dims: 2, Of: a}
+	return hm.NewFnType(t, G.TensorType{Dims: op.fwd.indexDims, Of: T.Int}, G.TensorType{Dims: op.fwd.indexDims + 1, Of: a}, t)
+}
+
+// InferShape implements gorgonia.Op.
+func (*embeddingDiffOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return inputs[0].(T.Shape).Clone(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *embeddingDiffOp) Do(inp ...G.Value) (G.Value, error) {
+	table, indices, grad := inp[0].(T.Tensor), inp[1].(T.Tensor), inp[2].(T.Tensor)
+	idxs := indices.Data().([]int)
+	dim := table.Shape()[1]
+	dTable := T.New(T.WithShape(table.Shape().Clone()...), T.Of(table.Dtype()))
+	switch table.Dtype() {
+	case T.Float64:
+		embeddingBackward(idxs, grad.Data().([]float64), dTable.Data().([]float64), dim, op.fwd.paddingIndex)
+	case T.Float32:
+		embeddingBackward(idxs, grad.Data().([]float32), dTable.Data().([]float32), dim, op.fwd.paddingIndex)
+	default:
+		return nil, fmt.Errorf("embedding can only be used on float64 and float32")
+	}
+	return dTable, nil
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*embeddingDiffOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*embeddingDiffOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*embeddingDiffOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *embeddingDiffOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *embeddingDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *embeddingDiffOp) String() string {
+	return fmt.Sprintf("EmbeddingDiffOp{%s}", op.fwd.name)
+}
+
+func embeddingBackward[F float32 | float64](indices []int, grad, dTable []F, dim, paddingIndex int) {
+	for i, idx := range indices {
+		if idx == paddingIndex {
+			continue
+		}
+		for j := 0; j < dim; j++ {
+			dTable[idx*dim+j] += grad[i*dim+j]
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package goras
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+
+	"github.com/chewxy/hm"
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+var _ G.Op = &pad2DOp{}
+var _ G.SDOp = &pad2DOp{}
+
+// pad2DOp adds zeros to the sides of the last two axes of a 4D tensor.
+// Negative amounts crop that side instead, so the same op is used for cropping, and the gradient of a pad is just a crop (and vice versa).
+type pad2DOp struct {
+	inShape T.Shape
+	pad     []int // [top, bottom, left, right]
+}
+
+// newPad2DOp creates a pad2DOp, checking that the crops do not remove the whole input.
+func newPad2DOp(inShape T.Shape, pad []int) (*pad2DOp, error) {
+	op := &pad2DOp{inShape.Clone(), pad}
+	out := op.outShape()
+	if out[2] < 1 || out[3] < 1 {
+		return nil, fmt.Errorf("cannot crop input of shape %v by %v, as there would be nothing left", inShape, negateInts(pad))
+	}
+	return op, nil
+}
+
+func (op *pad2DOp) outShape() T.Shape {
+	s := op.inShape.Clone()
+	s[2] += op.pad[0] + op.pad[1]
+	s[3] += op.pad[2] + op.pad[3]
+	return s
+}
+
+// Arity implements gorgonia.Op.
+func (*pad2DOp) Arity() int { return 1 }
+
+// Type implements gorgonia.Op.
+func (*pad2DOp) Type() hm.Type {
+	t := G.TensorType{Dims: 4, Of: hm.TypeVariable('a')}
+	return hm.NewFnType(t, t)
+}
+
+// InferShape implements gorgonia.Op.
+func (op *pad2DOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return op.outShape(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *pad2DOp) Do(inp ...G.Value) (G.Value, error) {
+	x := inp[0].(T.Tensor)
+	out := T.New(T.WithShape(op.outShape()...), T.Of(x.Dtype()))
+	switch x.Dtype() {
+	case T.Float64:
+		pad2DLoop(op, x.Data().([]float64), out.Data().([]float64))
+	case T.Float32:
+		pad2DLoop(op, x.Data().([]float32), out.Data().([]float32))
+	default:
+		return nil, fmt.Errorf("padding and cropping can only be used on float64 and float32")
+	}
+	return out, nil
+}
+
+// pad2DLoop copies every input row into the right place in the output, skipping any parts that have been cropped off.
+// The output must start as all zeros.
+func pad2DLoop[F float32 | float64](op *pad2DOp, x, out []F) {
+	outShape := op.outShape()
+	inH, inW, outH, outW := op.inShape[2], op.inShape[3], outShape[2], outShape[3]
+	top, left := op.pad[0], op.pad[2]
+	// The range of input columns that end up in the output
+	startW, endW := max(0, -left), min(inW, outW-left)
+	if startW >= endW {
+		return
+	}
+	for n := 0; n < op.inShape[0]*op.inShape[1]; n++ {
+		for ih := max(0, -top); ih < min(inH, outH-top); ih++ {
+			inRow := (n*inH + ih) * inW
+			outRow := (n*outH + ih + top) * outW
+			copy(out[outRow+startW+left:outRow+endW+left], x[inRow+startW:inRow+endW])
+		}
+	}
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*pad2DOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*pad2DOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*pad2DOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *pad2DOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *pad2DOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *pad2DOp) String() string {
+	return fmt.Sprintf("Pad2DOp{in=%v,pad=%v}", op.inShape, op.pad)
+}
+
+// DiffWRT implements gorgonia.SDOp.
+func (*pad2DOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+// SymDiff implements gorgonia.SDOp.
+// The gradient of padding is cropping the output grad back to the input shape, and the other way around for cropping.
+func (op *pad2DOp) SymDiff(inputs G.Nodes, output *G.Node, grad *G.Node) (G.Nodes, error) {
+	dx, err := G.ApplyOp(&pad2DOp{op.outShape(), negateInts(op.pad)}, grad)
+	if err != nil {
+		return nil, err
+	}
+	return G.Nodes{dx}, nil
+}
+
+func negateInts(xs []int) []int {
+	neg := make([]int, len(xs))
+	for i := range xs {
+		neg[i] = -xs[i]
+	}
+	return neg
+}
@@ -0,0 +1,229 @@
+package goras
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+
+	"github.com/chewxy/hm"
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+var _ G.Op = &poolNDOp{}
+var _ G.SDOp = &poolNDOp{}
+
+// poolNDOp is a max or average pooling op with any number of spatial dims.
+// The input is (batch, channels, ...spatial). Padded positions are ignored, so the max or average is only taken over real inputs.
+// This is used for all pooling layers apart from MaxPooling2D, as gorgonia only has 2D max pooling.
+type poolNDOp struct {
+	inShape   T.Shape
+	average   bool
+	poolSize  []int
+	stride    []int
+	padBefore []int
+	padAfter  []int
+	// inIndex is the result of windowIndices for this op.
+	inIndex []int
+}
+
+// newPoolNDOp creates a poolNDOp, working out which input position lines up with each output and window position.
+// If average is true the op takes the mean of each window, otherwise it takes the max.
+func newPoolNDOp(inShape T.Shape, average bool, poolSize, stride, padBefore, padAfter []int) (*poolNDOp, error) {
+	op := &poolNDOp{inShape.Clone(), average, poolSize, stride, padBefore, padAfter, nil}
+	spatialOut := op.outShape()[2:]
+	for i := range spatialOut {
+		if spatialOut[i] < 1 {
+			return nil, fmt.Errorf("pool size %v is too big for the input %v", poolSize, inShape)
+		}
+	}
+	op.inIndex = windowIndices(inShape[2:], T.Shape(poolSize), spatialOut, stride, filledInts(len(poolSize), 1), padBefore)
+	return op, nil
+}
+
+func (op *poolNDOp) outShape() T.Shape {
+	s := T.Shape{op.inShape[0], op.inShape[1]}
+	for d := 2; d < len(op.inShape); d++ {
+		i := d - 2
+		s = append(s, (op.inShape[d]+op.padBefore[i]+op.padAfter[i]-op.poolSize[i])/op.stride[i]+1)
+	}
+	return s
+}
+
+// Arity implements gorgonia.Op.
+func (*poolNDOp) Arity() int { return 1 }
+
+// Type implements gorgonia.Op.
+func (op *poolNDOp) Type() hm.Type {
+	t := G.TensorType{Dims: op.inShape.Dims(), Of: hm.TypeVariable('a')}
+	return hm.NewFnType(t, t)
+}
+
+// InferShape implements gorgonia.Op.
+func (op *poolNDOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return op.outShape(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *poolNDOp) Do(inp ...G.Value) (G.Value, error) {
+	x := inp[0].(T.Tensor)
+	out := T.New(T.WithShape(op.outShape()...), T.Of(x.Dtype()))
+	switch x.Dtype() {
+	case T.Float64:
+		poolNDLoop(op, x.Data().([]float64), out.Data().([]float64), nil, nil)
+	case T.Float32:
+		poolNDLoop(op, x.Data().([]float32), out.Data().([]float32), nil, nil)
+	default:
+		return nil, fmt.Errorf("pooling can only be used on float64 and float32")
+	}
+	return out, nil
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*poolNDOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*poolNDOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*poolNDOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *poolNDOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *poolNDOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *poolNDOp) String() string {
+	return fmt.Sprintf("PoolNDOp{in=%v,average=%v,pool=%v,stride=%v,pad=%v/%v}", op.inShape, op.average, op.poolSize, op.stride, op.padBefore, op.padAfter)
+}
+
+// DiffWRT implements gorgonia.SDOp.
+func (*poolNDOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+// SymDiff implements gorgonia.SDOp.
+func (op *poolNDOp) SymDiff(inputs G.Nodes, output *G.Node, grad *G.Node) (G.Nodes, error) {
+	dx, err := G.ApplyOp(&poolNDDiffOp{op}, inputs[0], grad)
+	if err != nil {
+		return nil, err
+	}
+	return G.Nodes{dx}, nil
+}
+
+// poolNDLoop finds the max or mean of each window of x.
+// If out is not nil, the result is written to it. If dx is not nil, dy (the output grad) is passed back to dx.
+func poolNDLoop[F float32 | float64](op *poolNDOp, x, out, dy, dx []F) {
+	numChannels := op.inShape[0] * op.inShape[1]
+	numIn, numWindow := op.inShape[2:].TotalSize(), T.Shape(op.poolSize).TotalSize()
+	numOut := len(op.inIndex) / numWindow
+	for c := 0; c < numChannels; c++ {
+		xOffset, yOffset := c*numIn, c*numOut
+		for p := 0; p < numOut; p++ {
+			window := op.inIndex[p*numWindow : (p+1)*numWindow]
+			if op.average {
+				var sum, count F
+				for _, ip := range window {
+					if ip >= 0 {
+						sum += x[xOffset+ip]
+						count++
+					}
+				}
+				if count == 0 {
+					continue
+				}
+				if out != nil {
+					out[yOffset+p] = sum / count
+				}
+				if dx != nil {
+					for _, ip := range window {
+						if ip >= 0 {
+							dx[xOffset+ip] += dy[yOffset+p] / count
+						}
+					}
+				}
+				continue
+			}
+			best := -1
+			for _, ip := range window {
+				if ip >= 0 && (best < 0 || x[xOffset+ip] > x[xOffset+best]) {
+					best = ip
+				}
+			}
+			if best < 0 {
+				continue
+			}
+			if out != nil {
+				out[yOffset+p] = x[xOffset+best]
+			}
+			if dx != nil {
+				dx[xOffset+best] += dy[yOffset+p]
+			}
+		}
+	}
+}
+
+var _ G.Op = &poolNDDiffOp{}
+
+// poolNDDiffOp calculates the gradient of a poolNDOp wrt its input.
+// It takes (x, outputGrad) as inputs, and passes the grad back to the max of each window, or shares it between the whole window for average pooling.
+type poolNDDiffOp struct {
+	fwd *poolNDOp
+}
+
+// Arity implements gorgonia.Op.
+func (*poolNDDiffOp) Arity() int { return 2 }
+
+// Type implements gorgonia.Op.
+func (op *poolNDDiffOp) Type() hm.Type {
+	t := G.TensorType{Dims: op.fwd.inShape.Dims(), Of: hm.TypeVariable('a')}
+	return hm.NewFnType(t, t, t)
+}
+
+// InferShape implements gorgonia.Op.
+func (op *poolNDDiffOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return op.fwd.inShape.Clone(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *poolNDDiffOp) Do(inp ...G.Value) (G.Value, error) {
+	x, dy := inp[0].(T.Tensor), inp[1].(T.Tensor)
+	dx := T.New(T.WithShape(op.fwd.inShape.Clone()...), T.Of(x.Dtype()))
+	switch x.Dtype() {
+	case T.Float64:
+		poolNDLoop(op.fwd, x.Data().([]float64), nil, dy.Data().([]float64), dx.Data().([]float64))
+	case T.Float32:
+		poolNDLoop(op.fwd, x.Data().([]float32), nil, dy.Data().([]float32), dx.Data().([]float32))
+	default:
+		return nil, fmt.Errorf("pooling can only be used on float64 and float32")
+	}
+	return dx, nil
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*poolNDDiffOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*poolNDDiffOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*poolNDDiffOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *poolNDDiffOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *poolNDDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *poolNDDiffOp) String() string {
+	return fmt.Sprintf("PoolNDDiffOp{in=%v,average=%v,pool=%v,stride=%v,pad=%v/%v}", op.fwd.inShape, op.fwd.average, op.fwd.poolSize, op.fwd.stride, op.fwd.padBefore, op.fwd.padAfter)
+}
@@ -0,0 +1,89 @@
+package goras
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+
+	"github.com/chewxy/hm"
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+var _ G.Op = &reshapeOp{}
+var _ G.SDOp = &reshapeOp{}
+
+// reshapeOp is a drop in replacement for G.Reshape.
+// G.Reshape shares the data of its input, but gorgonia does not seem to know that, so an op that works in place after the reshape can overwrite the input.
+// This is a problem when the input is a parameter, or is needed for the backwards pass. This op copies the data instead.
+type reshapeOp struct {
+	from, to T.Shape
+}
+
+// reshape reshapes the node n to the given shape.
+func reshape(n *G.Node, to T.Shape) (*G.Node, error) {
+	if n.Shape().TotalSize() != to.TotalSize() {
+		return nil, fmt.Errorf("cannot reshape %v to %v as they have different sizes", n.Shape(), to)
+	}
+	return G.ApplyOp(&reshapeOp{n.Shape().Clone(), to.Clone()}, n)
+}
+
+// Arity implements gorgonia.Op.
+func (*reshapeOp) Arity() int { return 1 }
+
+// Type implements gorgonia.Op.
+func (op *reshapeOp) Type() hm.Type {
+	a := hm.TypeVariable('a')
+	return hm.NewFnType(G.TensorType{Dims: op.from.Dims(), Of: a}, G.TensorType{Dims: op.to.Dims(), Of: a})
+}
+
+// InferShape implements gorgonia.Op.
+func (op *reshapeOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return op.to.Clone(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *reshapeOp) Do(inp ...G.Value) (G.Value, error) {
+	x := inp[0].(T.Tensor)
+	out := T.New(T.WithShape(op.to...), T.Of(x.Dtype()))
+	if err := T.Copy(out, T.New(T.WithShape(op.to...), T.WithBacking(x.Data()))); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*reshapeOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*reshapeOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*reshapeOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *reshapeOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *reshapeOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *reshapeOp) String() string {
+	return fmt.Sprintf("ReshapeOp%v->%v", op.from, op.to)
+}
+
+// DiffWRT implements gorgonia.SDOp.
+func (*reshapeOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+// SymDiff implements gorgonia.SDOp.
+func (op *reshapeOp) SymDiff(inputs G.Nodes, output *G.Node, grad *G.Node) (G.Nodes, error) {
+	dx, err := reshape(grad, op.from)
+	if err != nil {
+		return nil, err
+	}
+	return G.Nodes{dx}, nil
+}
@@ -0,0 +1,191 @@
+package goras
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+
+	"github.com/chewxy/hm"
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+var _ G.Op = &sliceAxisOp{}
+var _ G.SDOp = &sliceAxisOp{}
+
+// sliceAxisOp takes the range [start, end) of a single axis of its input, keeping the number of dims the same.
+// I have written this instead of using G.Slice because G.Slice removes any axis that ends up with a size of 1, which breaks things like matrix multiplication.
+type sliceAxisOp struct {
+	from       T.Shape
+	axis       int
+	start, end int
+}
+
+// sliceAxis returns the range [start, end) of the given axis of n.
+func sliceAxis(n *G.Node, axis, start, end int) (*G.Node, error) {
+	if axis < 0 || axis >= n.Dims() {
+		return nil, fmt.Errorf("cannot slice axis %v of a node with %v dims", axis, n.Dims())
+	}
+	if start < 0 || end > n.Shape()[axis] || start >= end {
+		return nil, fmt.Errorf("cannot slice [%v, %v) from axis %v with size %v", start, end, axis, n.Shape()[axis])
+	}
+	return G.ApplyOp(&sliceAxisOp{n.Shape().Clone(), axis, start, end}, n)
+}
+
+// Arity implements gorgonia.Op.
+func (*sliceAxisOp) Arity() int { return 1 }
+
+// Type implements gorgonia.Op.
+func (op *sliceAxisOp) Type() hm.Type {
+	t := G.TensorType{Dims: op.from.Dims(), Of: hm.TypeVariable('a')}
+	return hm.NewFnType(t, t)
+}
+
+// InferShape implements gorgonia.Op.
+func (op *sliceAxisOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return op.toShape(), nil
+}
+
+func (op *sliceAxisOp) toShape() T.Shape {
+	s := op.from.Clone()
+	s[op.axis] = op.end - op.start
+	return s
+}
+
+// Do implements gorgonia.Op.
+func (op *sliceAxisOp) Do(inp ...G.Value) (G.Value, error) {
+	x := inp[0].(T.Tensor)
+	out := T.New(T.WithShape(op.toShape()...), T.Of(x.Dtype()))
+	if err := copyAxisRange(x.Data(), out.Data(), op.from, op.axis, op.start, op.end, false); err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*sliceAxisOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*sliceAxisOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*sliceAxisOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *sliceAxisOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *sliceAxisOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *sliceAxisOp) String() string {
+	return fmt.Sprintf("SliceAxisOp%v{axis=%v,%v:%v}", op.from, op.axis, op.start, op.end)
+}
+
+// DiffWRT implements gorgonia.SDOp.
+func (*sliceAxisOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+// SymDiff implements gorgonia.SDOp.
+func (op *sliceAxisOp) SymDiff(inputs G.Nodes, output *G.Node, grad *G.Node) (G.Nodes, error) {
+	dx, err := G.ApplyOp(&sliceAxisDiffOp{op}, grad)
+	if err != nil {
+		return nil, err
+	}
+	return G.Nodes{dx}, nil
+}
+
+var _ G.Op = &sliceAxisDiffOp{}
+
+// sliceAxisDiffOp calculates the gradient of a sliceAxisOp wrt its input.
+// It takes the output grad and puts it back into the sliced range of a zeroed tensor with the shape of the input.
+type sliceAxisDiffOp struct {
+	fwd *sliceAxisOp
+}
+
+// Arity implements gorgonia.Op.
+func (*sliceAxisDiffOp) Arity() int { return 1 }
+
+// Type implements gorgonia.Op.
+func (op *sliceAxisDiffOp) Type() hm.Type {
+	t := G.TensorType{Dims: op.fwd.from.Dims(), Of: hm.TypeVariable('a')}
+	return hm.NewFnType(t, t)
+}
+
+// InferShape implements gorgonia.Op.
+func (op *sliceAxisDiffOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return op.fwd.from.Clone(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *sliceAxisDiffOp) Do(inp ...G.Value) (G.Value, error) {
+	grad := inp[0].(T.Tensor)
+	dx := T.New(T.WithShape(op.fwd.from.Clone()...), T.Of(grad.Dtype()))
+	if err := copyAxisRange(dx.Data(), grad.Data(), op.fwd.from, op.fwd.axis, op.fwd.start, op.fwd.end, true); err != nil {
+		return nil, err
+	}
+	return dx, nil
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*sliceAxisDiffOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*sliceAxisDiffOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*sliceAxisDiffOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *sliceAxisDiffOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *sliceAxisDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *sliceAxisDiffOp) String() string {
+	return fmt.Sprintf("SliceAxisDiffOp%v{axis=%v,%v:%v}", op.fwd.from, op.fwd.axis, op.fwd.start, op.fwd.end)
+}
+
+// copyAxisRange copies between full, which has the shape fullShape, and sliced, which is the range [start, end) of the axis.
+// If intoFull is true, sliced is copied into full, otherwise full is copied into sliced.
+func copyAxisRange(full, sliced interface{}, fullShape T.Shape, axis, start, end int, intoFull bool) error {
+	switch full := full.(type) {
+	case []float64:
+		copyAxisRangeT(full, sliced.([]float64), fullShape, axis, start, end, intoFull)
+	case []float32:
+		copyAxisRangeT(full, sliced.([]float32), fullShape, axis, start, end, intoFull)
+	case []int:
+		copyAxisRangeT(full, sliced.([]int), fullShape, axis, start, end, intoFull)
+	default:
+		return fmt.Errorf("slicing is not implemented for %T", full)
+	}
+	return nil
+}
+
+func copyAxisRangeT[E any](full, sliced []E, fullShape T.Shape, axis, start, end int, intoFull bool) {
+	outer, inner := 1, 1
+	for _, s := range fullShape[:axis] {
+		outer *= s
+	}
+	for _, s := range fullShape[axis+1:] {
+		inner *= s
+	}
+	axisSize, slicedSize := fullShape[axis], end-start
+	for o := 0; o < outer; o++ {
+		fullStart := (o*axisSize + start) * inner
+		slicedStart := o * slicedSize * inner
+		if intoFull {
+			copy(full[fullStart:fullStart+slicedSize*inner], sliced[slicedStart:slicedStart+slicedSize*inner])
+		} else {
+			copy(sliced[slicedStart:slicedStart+slicedSize*inner], full[fullStart:fullStart+slicedSize*inner])
+		}
+	}
+}
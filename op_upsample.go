@@ -0,0 +1,204 @@
+package goras
+
+import (
+	"fmt"
+	"hash"
+	"hash/fnv"
+	"math"
+
+	"github.com/chewxy/hm"
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+var _ G.Op = &upSample2DOp{}
+var _ G.SDOp = &upSample2DOp{}
+
+// upSample2DOp scales up the last two axes of a 4D tensor by integer factors.
+// Each output pixel is a weighted sum of the (up to) four nearest input pixels, so the backwards pass just does the same sum in reverse.
+// Bilinear sampling uses the same half-pixel centres as Keras, and clamps at the edges.
+type upSample2DOp struct {
+	inShape  T.Shape
+	factors  []int
+	bilinear bool
+}
+
+// upSampleTaps returns, for each output position along an axis, the two input positions that it reads from and the weight of the second.
+func (op *upSample2DOp) upSampleTaps(inSize, factor int) (lo, hi []int, w []float64) {
+	outSize := inSize * factor
+	lo, hi, w = make([]int, outSize), make([]int, outSize), make([]float64, outSize)
+	for p := 0; p < outSize; p++ {
+		if !op.bilinear {
+			lo[p], hi[p] = p/factor, p/factor
+			continue
+		}
+		src := (float64(p)+0.5)/float64(factor) - 0.5
+		src = math.Max(0, math.Min(src, float64(inSize-1)))
+		lo[p] = int(math.Floor(src))
+		hi[p] = min(lo[p]+1, inSize-1)
+		w[p] = src - float64(lo[p])
+	}
+	return
+}
+
+func (op *upSample2DOp) outShape() T.Shape {
+	s := op.inShape.Clone()
+	s[2] *= op.factors[0]
+	s[3] *= op.factors[1]
+	return s
+}
+
+// Arity implements gorgonia.Op.
+func (*upSample2DOp) Arity() int { return 1 }
+
+// Type implements gorgonia.Op.
+func (*upSample2DOp) Type() hm.Type {
+	t := G.TensorType{Dims: 4, Of: hm.TypeVariable('a')}
+	return hm.NewFnType(t, t)
+}
+
+// InferShape implements gorgonia.Op.
+func (op *upSample2DOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return op.outShape(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *upSample2DOp) Do(inp ...G.Value) (G.Value, error) {
+	x := inp[0].(T.Tensor)
+	out := T.New(T.WithShape(op.outShape()...), T.Of(x.Dtype()))
+	switch x.Dtype() {
+	case T.Float64:
+		upSample2DLoop(op, x.Data().([]float64), out.Data().([]float64), false)
+	case T.Float32:
+		upSample2DLoop(op, x.Data().([]float32), out.Data().([]float32), false)
+	default:
+		return nil, fmt.Errorf("upsampling can only be used on float64 and float32")
+	}
+	return out, nil
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*upSample2DOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*upSample2DOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*upSample2DOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *upSample2DOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *upSample2DOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *upSample2DOp) String() string {
+	return fmt.Sprintf("UpSample2DOp%v{factors=%v,bilinear=%v}", op.inShape, op.factors, op.bilinear)
+}
+
+// DiffWRT implements gorgonia.SDOp.
+func (*upSample2DOp) DiffWRT(inputs int) []bool { return []bool{true} }
+
+// SymDiff implements gorgonia.SDOp.
+func (op *upSample2DOp) SymDiff(inputs G.Nodes, output *G.Node, grad *G.Node) (G.Nodes, error) {
+	dx, err := G.ApplyOp(&upSample2DDiffOp{op}, grad)
+	if err != nil {
+		return nil, err
+	}
+	return G.Nodes{dx}, nil
+}
+
+// upSample2DLoop either upsamples small into big, or if reverse is true, adds up the grads in big into small.
+func upSample2DLoop[F float32 | float64](op *upSample2DOp, small, big []F, reverse bool) {
+	batchSize, channels, inH, inW := op.inShape[0], op.inShape[1], op.inShape[2], op.inShape[3]
+	outH, outW := inH*op.factors[0], inW*op.factors[1]
+	loH, hiH, wH := op.upSampleTaps(inH, op.factors[0])
+	loW, hiW, wW := op.upSampleTaps(inW, op.factors[1])
+	for bc := 0; bc < batchSize*channels; bc++ {
+		smallOffset, bigOffset := bc*inH*inW, bc*outH*outW
+		for p := 0; p < outH; p++ {
+			for q := 0; q < outW; q++ {
+				taps := [4]int{loH[p]*inW + loW[q], loH[p]*inW + hiW[q], hiH[p]*inW + loW[q], hiH[p]*inW + hiW[q]}
+				weights := [4]float64{(1 - wH[p]) * (1 - wW[q]), (1 - wH[p]) * wW[q], wH[p] * (1 - wW[q]), wH[p] * wW[q]}
+				bi := bigOffset + p*outW + q
+				for t := range taps {
+					if weights[t] == 0 {
+						continue
+					}
+					si := smallOffset + taps[t]
+					if reverse {
+						small[si] += F(weights[t]) * big[bi]
+					} else {
+						big[bi] += F(weights[t]) * small[si]
+					}
+				}
+			}
+		}
+	}
+}
+
+var _ G.Op = &upSample2DDiffOp{}
+
+// upSample2DDiffOp calculates the gradient of an upSample2DOp wrt its input.
+// It takes the output grad as its only input.
+type upSample2DDiffOp struct {
+	fwd *upSample2DOp
+}
+
+// Arity implements gorgonia.Op.
+func (*upSample2DDiffOp) Arity() int { return 1 }
+
+// Type implements gorgonia.Op.
+func (*upSample2DDiffOp) Type() hm.Type {
+	t := G.TensorType{Dims: 4, Of: hm.TypeVariable('a')}
+	return hm.NewFnType(t, t)
+}
+
+// InferShape implements gorgonia.Op.
+func (op *upSample2DDiffOp) InferShape(inputs ...G.DimSizer) (T.Shape, error) {
+	return op.fwd.inShape.Clone(), nil
+}
+
+// Do implements gorgonia.Op.
+func (op *upSample2DDiffOp) Do(inp ...G.Value) (G.Value, error) {
+	grad := inp[0].(T.Tensor)
+	dx := T.New(T.WithShape(op.fwd.inShape.Clone()...), T.Of(grad.Dtype()))
+	switch grad.Dtype() {
+	case T.Float64:
+		upSample2DLoop(op.fwd, dx.Data().([]float64), grad.Data().([]float64), true)
+	case T.Float32:
+		upSample2DLoop(op.fwd, dx.Data().([]float32), grad.Data().([]float32), true)
+	default:
+		return nil, fmt.Errorf("upsampling can only be used on float64 and float32")
+	}
+	return dx, nil
+}
+
+// ReturnsPtr implements gorgonia.Op.
+func (*upSample2DDiffOp) ReturnsPtr() bool { return false }
+
+// CallsExtern implements gorgonia.Op.
+func (*upSample2DDiffOp) CallsExtern() bool { return false }
+
+// OverwritesInput implements gorgonia.Op.
+func (*upSample2DDiffOp) OverwritesInput() int { return -1 }
+
+// WriteHash implements gorgonia.Op.
+func (op *upSample2DDiffOp) WriteHash(h hash.Hash) { fmt.Fprint(h, op.String()) }
+
+// Hashcode implements gorgonia.Op.
+func (op *upSample2DDiffOp) Hashcode() uint32 {
+	h := fnv.New32a()
+	op.WriteHash(h)
+	return h.Sum32()
+}
+
+// String implements gorgonia.Op.
+func (op *upSample2DDiffOp) String() string {
+	return fmt.Sprintf("UpSample2DDiffOp%v{factors=%v,bilinear=%v}", op.fwd.inShape, op.fwd.factors, op.fwd.bilinear)
+}
@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"fmt"
 	"math"
+	"strings"
 	"testing"
 
 	G "gorgonia.org/gorgonia"
@@ -85,6 +86,41 @@ func TestXor(t *testing.T) {
 	}
 }
 
+func TestSequential(t *testing.T) {
+	model, err := Sequential("model", T.Float64, []int{4, 2}, MSELoss,
+		Seq1(Dense, 5),
+		Seq1(Activation, "sigmoid"),
+		Seq1(Dense, 1),
+		Seq(Sigmoid),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// It should be exactly the same as building the model by hand
+	handModel, err := makeXORModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if model.Summary() != handModel.Summary() {
+		t.Fatalf("expected the same summary as the hand built model, got\n%v\nbut expected\n%v", model.Summary(), handModel.Summary())
+	}
+	if err := model.SetParams(handModel.GetParams()); err != nil {
+		t.Fatal(err)
+	}
+	x, y := loadXORXY()
+	if err := model.Fit(NamedTs{"x": x}, NamedTs{"yt": y}, G.NewAdamSolver(G.WithLearnRate(0.01)), WithEpochs(10), WithVerbose(false)); err != nil {
+		t.Fatal(err)
+	}
+
+	// Errors attaching layers should be returned
+	if _, err := Sequential("model", T.Float64, []int{4}, MSELoss, Seq(Flatten)); err == nil {
+		t.Fatal("expected an error flattening a 1D input")
+	}
+	if _, err := Sequential("model", T.Float64, []int{4, 2}, MSELoss); err == nil {
+		t.Fatal("expected an error with no layers")
+	}
+}
+
 // Dosent build the model
 func makeUnfinishedXORModel() (*Model, *G.Node, *G.Node, error) {
 	batchSize := 4
@@ -349,6 +385,94 @@ func TestActivations(t *testing.T) {
 	if err := testActivation(lr001, x, lr001Y); err != nil {
 		t.Fatal(err)
 	}
+
+	newActivations := []struct {
+		ac func(*Model, string) *ActivationLayer
+		y  [][]float32
+	}{
+		{GELU, [][]float32{{-0.046017, 0.185371, 0.345714}, {-0.045402, 0.841192, 1.954598}}},
+		{Swish, [][]float32{{-0.047502, 0.172333, 0.31123}, {-0.238406, 0.731059, 1.761594}}},
+		{SiLU, [][]float32{{-0.047502, 0.172333, 0.31123}, {-0.238406, 0.731059, 1.761594}}},
+		{ELU, [][]float32{{-0.095163, 0.3, 0.5}, {-0.864665, 1, 2}}},
+		{SELU, [][]float32{{-0.167305, 0.31521, 0.52535}, {-1.520166, 1.050701, 2.101402}}},
+		{Softplus, [][]float32{{0.644397, 0.854355, 0.974077}, {0.126928, 1.313262, 2.126928}}},
+		{Softsign, [][]float32{{-0.090909, 0.230769, 0.333333}, {-0.666667, 0.5, 0.666667}}},
+		{HardSigmoid, [][]float32{{0.48, 0.56, 0.6}, {0.1, 0.7, 0.9}}},
+		{func(m *Model, name string) *ActivationLayer { return LogSoftmax(m, name) }, [][]float32{{-1.461852, -1.061852, -0.861852}, {-4.326563, -1.326563, -0.326563}}},
+	}
+	for _, a := range newActivations {
+		y, _ := Make2DSliceTensor(a.y)
+		if err := testActivation(a.ac, x, y); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Custom activations can be registered and then used by name
+	if err := RegisterActivation("relu", func(_ *ActivationLayer, x *G.Node) (*G.Node, error) { return x, nil }); err == nil {
+		t.Fatal("expected an error registering an activation that already exists")
+	}
+	if err := RegisterActivation("test_double", func(l *ActivationLayer, x *G.Node) (*G.Node, error) {
+		return G.Mul(x, G.NewConstant(float32(2), G.WithName(l.Name()+".two")))
+	}); err != nil && !strings.Contains(err.Error(), "already") {
+		t.Fatal(err)
+	}
+	double := func(m *Model, name string) *ActivationLayer { return Activation(m, name, "test_double") }
+	doubleY, _ := Make2DSliceTensor([][]float32{{-0.2, 0.6, 1}, {-4, 2, 4}})
+	if err := testActivation(double, x, doubleY); err != nil {
+		t.Fatal(err)
+	}
+	model, err := makeSingleActivationModel(double, T.Float32)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(model.Summary(), "activation(test_double)") {
+		t.Fatalf("expected the activation name in the summary, got\n%v", model.Summary())
+	}
+	if _, err := makeSingleActivationModel(func(m *Model, name string) *ActivationLayer { return Activation(m, name, "not_an_activation") }, T.Float32); err == nil {
+		t.Fatal("expected an error using an unregistered activation")
+	}
+
+	// Softmax should not overflow with large inputs, even in float32
+	extremeX, _ := Make2DSliceTensor([][]float32{{1000, 0, -1000}, {100, 100, -100}})
+	extremeActivations := []struct {
+		ac func(*Model, string) *ActivationLayer
+		y  [][]float32
+	}{
+		{func(m *Model, name string) *ActivationLayer { return Softmax(m, name) }, [][]float32{{1, 0, 0}, {0.5, 0.5, 0}}},
+		{func(m *Model, name string) *ActivationLayer { return LogSoftmax(m, name) }, [][]float32{{0, -1000, -2000}, {-0.693147, -0.693147, -200.693147}}},
+		{Softplus, [][]float32{{1000, 0.693147, 0}, {100, 100, 0}}},
+		{ELU, [][]float32{{1000, 0, -1}, {100, 100, -1}}},
+		{Sigmoid, [][]float32{{1, 0.5, 0}, {1, 1, 0}}},
+	}
+	for _, a := range extremeActivations {
+		y, _ := Make2DSliceTensor(a.y)
+		if err := testActivation(a.ac, extremeX, y); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Softmax can be applied along any axis, such as the last axis of (batch, seq, classes)
+	model = NewModel()
+	inputs := Input(model, "input", T.Float32, 1, 2, 3).Node()
+	outputs := Softmax(model, "softmax", 1).MustAttach(inputs)
+	model.MustBuild(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+	x3 := T.New(T.WithShape(1, 2, 3), T.WithBacking([]float32{1000, 0, 2, 1000, 0, 2}))
+	ys := model.MustPredict(NamedTs{"x": x3})
+	if fmt.Sprint(ys["yp"].Data()) != "[0.5 0.5 0.5 0.5 0.5 0.5]" {
+		t.Fatalf("wrong softmax along axis 1: %v", ys["yp"].Data())
+	}
+	model = NewModel()
+	inputs = Input(model, "input", T.Float32, 1, 2, 3).Node()
+	outputs = Softmax(model, "softmax").MustAttach(inputs)
+	model.MustBuild(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+	ys = model.MustPredict(NamedTs{"x": x3})
+	if fmt.Sprint(ys["yp"].Data()) != "[1 0 0 1 0 0]" {
+		t.Fatalf("wrong softmax along the last axis: %v", ys["yp"].Data())
+	}
+	loss := model.MustFitBatch(NamedTs{"x": x3}, NamedTs{"yt": T.New(T.WithShape(1, 2, 3), T.WithBacking([]float32{0, 1, 0, 0, 0, 1}))}, G.NewVanillaSolver())
+	if math.IsNaN(loss) || math.IsInf(loss, 0) {
+		t.Fatalf("softmax gave a loss of %v with large inputs", loss)
+	}
 }
 
 func TestOneHot(t *testing.T) {
@@ -391,6 +515,110 @@ func TestOneHot(t *testing.T) {
 
 }
 
+func TestInitializers(t *testing.T) {
+	stats := func(vals []float64) (float64, float64) {
+		mean, variance := 0.0, 0.0
+		for _, v := range vals {
+			mean += v / float64(len(vals))
+		}
+		for _, v := range vals {
+			variance += (v - mean) * (v - mean) / float64(len(vals))
+		}
+		return mean, math.Sqrt(variance)
+	}
+	// Dense shape (400, 100), so fan in is 400 and fan out is 100
+	expectedStds := map[string]float64{
+		"glorot_normal":  math.Sqrt(2.0 / 500),
+		"glorot_uniform": math.Sqrt(6.0/500) / math.Sqrt(3),
+		"he_normal":      math.Sqrt(2.0 / 400),
+		"he_uniform":     math.Sqrt(6.0/400) / math.Sqrt(3),
+		"lecun_normal":   math.Sqrt(1.0 / 400),
+		"lecun_uniform":  math.Sqrt(3.0/400) / math.Sqrt(3),
+	}
+	initializers := map[string]G.InitWFn{
+		"glorot_normal":  GlorotNormal(),
+		"glorot_uniform": GlorotUniform(),
+		"he_normal":      HeNormal(),
+		"he_uniform":     HeUniform(),
+		"lecun_normal":   LeCunNormal(),
+		"lecun_uniform":  LeCunUniform(),
+	}
+	for name, init := range initializers {
+		mean, std := stats(init(T.Float64, 400, 100).([]float64))
+		if math.Abs(mean) > 0.01 || math.Abs(std-expectedStds[name])/expectedStds[name] > 0.05 {
+			t.Errorf("%v: expected mean 0 and std %v but got mean %v and std %v", name, expectedStds[name], mean, std)
+		}
+		if vals := init(T.Float32, 2, 3, 3, 3).([]float32); len(vals) != 54 {
+			t.Errorf("%v: expected 54 float32 values but got %v", name, len(vals))
+		}
+	}
+	if fmt.Sprint(Constant(0.5)(T.Float64, 2, 2)) != "[0.5 0.5 0.5 0.5]" || fmt.Sprint(Zeros()(T.Float32, 3)) != "[0 0 0]" || fmt.Sprint(Ones()(T.Float64, 1, 2)) != "[1 1]" {
+		t.Error("constant initializers gave the wrong values")
+	}
+
+	// Orthogonal weights should have orthonormal columns if they are tall, and orthonormal rows if they are wide
+	for _, shape := range [][]int{{6, 4}, {3, 5}, {4, 2, 3, 3}} {
+		rows, cols := shape[0], T.Shape(shape[1:]).TotalSize()
+		w := Orthogonal(1)(T.Float64, shape...).([]float64)
+		n, stride, step := cols, 1, cols
+		if rows < cols {
+			n, stride, step = rows, cols, 1
+		}
+		for a := 0; a < n; a++ {
+			for b := 0; b < n; b++ {
+				dot := 0.0
+				for k := 0; k < len(w)/n; k++ {
+					dot += w[a*stride+k*step] * w[b*stride+k*step]
+				}
+				expected := 0.0
+				if a == b {
+					expected = 1
+				}
+				if math.Abs(dot-expected) > 1e-9 {
+					t.Fatalf("orthogonal weights with shape %v are not orthonormal: dot of %v and %v is %v", shape, a, b, dot)
+				}
+			}
+		}
+	}
+
+	// Layers should use the model default unless they are told otherwise
+	model := NewModel()
+	model.DefaultInitializer = Zeros()
+	namer := NewNamer("model")
+	inputs := Input(model, namer(), T.Float64, 2, 3).Node()
+	dense1 := Dense(model, namer(), 4)
+	outputs := dense1.MustAttach(inputs)
+	dense2 := Dense(model, namer(), 1)
+	dense2.Initializer = func(dt T.Dtype, s ...int) interface{} { return T.Range(dt, 0, T.Shape(s).TotalSize()) }
+	outputs = dense2.MustAttach(outputs)
+	model.MustBuild(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+	if fmt.Sprint(dense1.Weights.Value().Data()) != fmt.Sprint(make([]float64, 12)) {
+		t.Fatalf("expected the model default initializer to be used, but got %v", dense1.Weights.Value().Data())
+	}
+	if fmt.Sprint(dense2.Weights.Value().Data()) != "[0 1 2 3]" {
+		t.Fatalf("expected the layer initializer to be used, but got %v", dense2.Weights.Value().Data())
+	}
+
+	// Conv2DTranspose kernels are (inputs, outputs, kh, kw), so the fan in should come from the first axis
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 1, 40, 2, 2).Node()
+	convT := Conv2DTranspose(model, "convt", []int{3, 3}, []int{1, 1}, "same", 4)
+	convT.Initializer = HeNormal()
+	convT.MustAttach(inputs)
+	if mean, std := stats(convT.Kernels.Value().Data().([]float64)); math.Abs(mean) > 0.01 || math.Abs(std-math.Sqrt(2.0/360))/math.Sqrt(2.0/360) > 0.1 {
+		t.Fatalf("expected Conv2DTranspose kernels with mean 0 and std %v but got mean %v and std %v", math.Sqrt(2.0/360), mean, std)
+	}
+	// The initializer is given the shape (outputs, inputs, kh, kw), and the first two axes of its values are swapped
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 1, 3, 2, 2).Node()
+	convT = Conv2DTranspose(model, "convt", []int{1, 1}, []int{1, 1}, "same", 2)
+	convT.Initializer = func(dt T.Dtype, s ...int) interface{} { return T.Range(dt, 0, T.Shape(s).TotalSize()) }
+	convT.MustAttach(inputs)
+	if fmt.Sprint(convT.Kernels.Value().Data()) != "[0 3 1 4 2 5]" {
+		t.Fatalf("expected the Conv2DTranspose kernels to be transposed, but got %v", convT.Kernels.Value().Data())
+	}
+}
+
 func TestModelSaveLoad(t *testing.T) {
 	model, err := makeXORModel()
 	if err != nil {
@@ -426,6 +654,179 @@ func TestModelSaveLoad(t *testing.T) {
 	}
 }
 
+func TestSetTrainable(t *testing.T) {
+	model := NewModel()
+	inputs := Input(model, "input", T.Float64, 4, 2).Node()
+	outputs := Dense(model, "base_1", 3).MustAttach(inputs)
+	outputs = Dense(model, "base_2", 3).MustAttach(outputs)
+	outputs = Dense(model, "head", 1).MustAttach(outputs)
+	// Freezing before building should not stop the layer being unfrozen later
+	model.MustSetTrainable("base_2", false)
+	model.MustBuild(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+	x, y := loadXORXY()
+	solver := G.NewAdamSolver(G.WithLearnRate(0.01))
+
+	changedParams := func(fit func()) map[string]bool {
+		before := model.GetParams()
+		for k, v := range before {
+			before[k] = v.Clone().(*T.Dense)
+		}
+		fit()
+		changed := make(map[string]bool)
+		for k, v := range model.GetParams() {
+			if !v.Eq(before[k]) {
+				changed[k] = true
+			}
+		}
+		return changed
+	}
+	fit := func() {
+		model.MustFit(NamedTs{"x": x}, NamedTs{"yt": y}, solver, WithEpochs(5), WithVerbose(false))
+	}
+
+	model.MustSetTrainable("base_*", false)
+	if len(model.Trainables()) != 2 {
+		t.Fatalf("expected only the head to be trainable, got %v", model.Trainables())
+	}
+	if changed := changedParams(fit); len(changed) != 2 || !changed["head:weights"] || !changed["head:biases"] {
+		t.Fatalf("expected only the head to change, but these changed: %v", changed)
+	}
+	model.MustSetTrainable("base_2", true)
+	if changed := changedParams(fit); len(changed) != 4 || changed["base_1:weights"] || changed["base_1:biases"] {
+		t.Fatalf("expected everything but base_1 to change, but these changed: %v", changed)
+	}
+	model.MustSetTrainable("*", true)
+	if changed := changedParams(fit); len(changed) != 6 {
+		t.Fatalf("expected everything to change, but these changed: %v", changed)
+	}
+	if err := model.SetTrainable("not_a_layer", false); err == nil {
+		t.Fatal("expected an error when no layers match")
+	}
+
+	// Adam should not build up moments for a frozen layer, so its first step after unfreezing starts from zero moments
+	// With zero moments, the first step of Adam moves every weight by the same amount: eta * ((1-beta1)/c1) / sqrt((1-beta2)/c2)
+	model.MustSetTrainable("base_1", false)
+	solver = G.NewAdamSolver(G.WithLearnRate(0.01))
+	for i := 0; i < 5; i++ {
+		model.MustFitBatch(NamedTs{"x": x}, NamedTs{"yt": y}, solver)
+	}
+	model.MustSetTrainable("base_1", true)
+	before := model.GetParams()["base_1:weights"].Clone().(*T.Dense)
+	model.MustFitBatch(NamedTs{"x": x}, NamedTs{"yt": y}, solver)
+	after := model.GetParams()["base_1:weights"]
+	c1, c2 := 1-math.Pow(0.9, 6), 1-math.Pow(0.999, 6)
+	expectedStep := 0.01 * (0.1 / c1) / math.Sqrt(0.001/c2)
+	for i, b := range before.Data().([]float64) {
+		if step := math.Abs(after.Data().([]float64)[i] - b); math.Abs(step-expectedStep) > 1e-6 {
+			t.Fatalf("expected the first step after unfreezing to be %v, but weight %v moved by %v", expectedStep, i, step)
+		}
+	}
+
+	// A model bound to this one should still share the weights of a layer after it has been frozen and unfrozen
+	bound := NewModel()
+	boundInputs := Input(bound, "input", T.Float64, 4, 2).Node()
+	boundOutputs := Dense(bound, "base_1", 3).MustAttach(boundInputs)
+	boundOutputs = Dense(bound, "base_2", 3).MustAttach(boundOutputs)
+	boundOutputs = Dense(bound, "head", 1).MustAttach(boundOutputs)
+	bound.MustBuild(WithInput("x", boundInputs), WithOutput("yp", boundOutputs), WithLoss(MSELoss("yt", boundOutputs)))
+	bound.MustBindParamsFrom(model)
+	model.MustSetTrainable("base_1", false)
+	model.MustFitBatch(NamedTs{"x": x}, NamedTs{"yt": y}, solver)
+	model.MustSetTrainable("base_1", true)
+	for i := 0; i < 3; i++ {
+		model.MustFitBatch(NamedTs{"x": x}, NamedTs{"yt": y}, solver)
+	}
+	for name, v := range model.GetParams() {
+		if !v.Eq(bound.GetParams()[name]) {
+			t.Fatalf("expected %v to still be shared with the bound model", name)
+		}
+	}
+}
+
+func TestVariableBatchSize(t *testing.T) {
+	model := NewModel()
+	inputs := Input(model, "input", T.Float64, 4, 3, 2).Node()
+	rnn := SimpleRNN(model, "rnn", 4)
+	rnn.ReturnSequences = true
+	outputs := rnn.MustAttach(inputs)
+	outputs = MultiHeadAttention(model, "attention", 2, 2).MustAttach(outputs)
+	outputs = Flatten(model, "flatten").MustAttach(outputs)
+	outputs = Dense(model, "dense_1", 5).MustAttach(outputs)
+	outputs = BatchNorm(model, "batchnorm").MustAttach(outputs)
+	outputs = Relu(model, "relu").MustAttach(outputs)
+	outputs = Dense(model, "dense_2", 2).MustAttach(outputs)
+	model.MustBuild(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+	x := T.New(T.WithShape(6, 3, 2), T.WithBacking(T.Range(T.Float64, 0, 36)))
+	x.Apply(func(v float64) float64 { return math.Sin(v) })
+	y := T.New(T.WithShape(4, 2), T.WithBacking(T.Range(T.Float64, 0, 8)))
+	fit := func() {
+		xb, _ := sliceBatch(x, T.S(0, 4))
+		model.MustFitBatch(NamedTs{"x": xb}, NamedTs{"yt": y}, G.NewAdamSolver(G.WithLearnRate(0.1)))
+	}
+	// checkPredictions checks that every batch size gives the same outputs as the batch size the model was built with
+	checkPredictions := func() {
+		full := model.MustPredict(NamedTs{"x": x})["yp"].(*T.Dense)
+		if !exactShapeEq(full.Shape(), T.Shape{6, 2}) {
+			t.Fatalf("expected predict to give an output for every row, got shape %v", full.Shape())
+		}
+		for _, batchSize := range []int{1, 2, 4, 6} {
+			xb, _ := sliceBatch(x, T.S(0, batchSize))
+			yb := model.MustPredictBatch(NamedTs{"x": xb})["yp"]
+			if !exactShapeEq(yb.Shape(), T.Shape{batchSize, 2}) {
+				t.Fatalf("wrong output shape %v for batch size %v", yb.Shape(), batchSize)
+			}
+			expected := full.Data().([]float64)[:batchSize*2]
+			for i, v := range yb.Data().([]float64) {
+				if math.Abs(v-expected[i]) > 1e-10 {
+					t.Fatalf("batch size %v gave %v, expected %v", batchSize, yb.Data(), expected)
+				}
+			}
+		}
+	}
+	checkPredictions()
+	// The copies of the model for the other batch sizes should see any changes to the parameters and state
+	fit()
+	checkPredictions()
+	params := model.GetParams()
+	for _, p := range params {
+		p.Apply(func(v float64) float64 { return v * 0.5 })
+	}
+	model.MustSetParams(params)
+	checkPredictions()
+	if len(model.batchSizeModels) != 3 {
+		t.Fatalf("expected a model to be cached for 3 other batch sizes, but there were %v", len(model.batchSizeModels))
+	}
+
+	// Only the batch size can change, and training still needs the batch size the model was built with
+	if _, err := model.PredictBatch(NamedTs{"x": T.New(T.WithShape(2, 2, 2), T.Of(T.Float64))}); err == nil {
+		t.Fatal("expected an error when the input has the wrong shape")
+	}
+	if _, err := model.FitBatch(NamedTs{"x": x}, NamedTs{"yt": T.New(T.WithShape(6, 2), T.Of(T.Float64))}, G.NewVanillaSolver()); err == nil {
+		t.Fatal("expected an error when fitting with a different batch size")
+	}
+
+	// Models that cannot be rebuilt should still be able to predict with padding
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 4, 2).Node()
+	var scale *G.Node
+	lambda := Lambda(model, "scale", func(xs ...*G.Node) (*G.Node, error) { return G.BroadcastHadamardProd(xs[0], scale, nil, []byte{0}) })
+	scale = lambda.MustAddParameter("scale", T.Float64, []int{2}, Ones())
+	outputs = lambda.MustAttach(inputs)
+	model.MustBuild(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+	_, err := model.PredictBatch(NamedTs{"x": T.New(T.WithShape(1, 2), T.Of(T.Float64))})
+	if err == nil {
+		t.Fatal("expected an error running a lambda layer with parameters at a different batch size")
+	}
+	// The failed rebuild should be remembered, rather than tried again every time
+	if _, err2 := model.PredictBatch(NamedTs{"x": T.New(T.WithShape(1, 2), T.Of(T.Float64))}); err2 != err {
+		t.Fatalf("expected the same error from the failed rebuild, got %v and %v", err, err2)
+	}
+	x = T.New(T.WithShape(5, 2), T.WithBacking(T.Range(T.Float64, 0, 10)))
+	if ys := model.MustPredict(NamedTs{"x": x}); !ys["yp"].(*T.Dense).Eq(x) {
+		t.Fatalf("expected the padded prediction to match the input, got %v", ys["yp"])
+	}
+}
+
 func testSimpleLoss(t *testing.T, name string, lf func(string, *G.Node) LossFunc, x, yt T.Tensor, lt float32) {
 	g := G.NewGraph()
 	inp := G.NewMatrix(g, T.Float32, G.WithShape(2, 3), G.WithName("fvdhubuv"))
@@ -451,6 +852,54 @@ func testSimpleLoss(t *testing.T, name string, lf func(string, *G.Node) LossFunc
 		t.Fatalf("wrong loss value for %v: %v, expected %v", name, lossVal, lt)
 	}
 }
+
+func TestRegularizers(t *testing.T) {
+	model := NewModel()
+	inputs := Input(model, "input", T.Float64, 2, 2).Node()
+	dense := Dense(model, "dense", 2)
+	dense.UseBias = false
+	dense.KernelRegularizer = L2(0.1)
+	dense.ActivityRegularizer = L1(0.01)
+	outputs := dense.MustAttach(inputs)
+	model.MustBuild(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+	model.MustSetParams(map[string]*T.Dense{"dense:weights": T.New(T.WithShape(2, 2), T.WithBacking([]float64{1, -2, 3, 0}))})
+
+	// Outputs are [[7, -2], [0, 6]], and the targets are zero
+	x := T.New(T.WithShape(2, 2), T.WithBacking([]float64{1, 2, -3, 1}))
+	yt := T.New(T.WithShape(2, 2), T.WithBacking([]float64{0, 0, 0, 0}))
+	loss := model.MustFitBatch(NamedTs{"x": x}, NamedTs{"yt": yt}, G.NewVanillaSolver(G.WithLearnRate(0)))
+	regLosses, err := model.RegularizationLosses()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]float64{
+		"dense:kernel":   0.1 * (1 + 4 + 9),
+		"dense:activity": 0.01 * (7 + 2 + 0 + 6) / 2,
+	}
+	if len(regLosses) != len(expected) {
+		t.Fatalf("expected regularization losses %v but got %v", expected, regLosses)
+	}
+	for name, val := range expected {
+		if math.Abs(regLosses[name]-val) > 1e-9 {
+			t.Fatalf("expected regularization loss %v to be %v but got %v", name, val, regLosses[name])
+		}
+	}
+	mse := (49.0 + 4 + 0 + 36) / 4
+	if math.Abs(loss-(mse+expected["dense:kernel"]+expected["dense:activity"])) > 1e-9 {
+		t.Fatalf("expected the regularization losses to be added to the loss, but got %v", loss)
+	}
+
+	// A regularizer with no penalty is a mistake
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 2, 2).Node()
+	dense = Dense(model, "dense", 2)
+	dense.KernelRegularizer = L1L2(0, 0)
+	outputs = dense.MustAttach(inputs)
+	if err := model.Build(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs))); err == nil {
+		t.Fatal("expected an error for a regularizer with no penalty")
+	}
+}
+
 func TestLosses(t *testing.T) {
 	x, err := Make2DSliceTensor(
 		[][]float32{
@@ -476,3 +925,1191 @@ func TestLosses(t *testing.T) {
 	/*targetCCEError := -(math.Log10(0.5) + math.Log10(0.9)) / 2
 	testSimpleLoss(t, "cce", CCELoss, x, yt, float32(targetCCEError))*/
 }
+
+func TestTrainingMode(t *testing.T) {
+	model := NewModel()
+	inputs := Input(model, "input", T.Float64, 4, 25).Node()
+	outputs := Dropout(model, "dropout", 0.5).MustAttach(inputs)
+	model.MustBuild(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+	x := T.New(T.WithShape(4, 25), T.WithBacking(T.Range(T.Float64, 1, 101)))
+
+	// While predicting, dropout should do nothing
+	for i := 0; i < 2; i++ {
+		ys := model.MustPredictBatch(NamedTs{"x": x})
+		if model.IsTraining() {
+			t.Fatal("expected the model to not be training after PredictBatch")
+		}
+		if !ys["yp"].(*T.Dense).Eq(x) {
+			t.Fatalf("expected dropout to do nothing while predicting, got %v", ys["yp"])
+		}
+	}
+
+	// While training, roughly half of the values should be dropped and the rest doubled
+	model.MustFitBatch(NamedTs{"x": x}, NamedTs{"yt": x}, G.NewVanillaSolver())
+	if !model.IsTraining() {
+		t.Fatal("expected the model to be training after FitBatch")
+	}
+	dropped := 0
+	for i, v := range (*model.OutputValues["yp"]).Data().([]float64) {
+		if v == 0 {
+			dropped++
+		} else if v != 2*float64(i+1) {
+			t.Fatalf("expected kept values to be scaled by 2, got %v for %v", v, i+1)
+		}
+	}
+	if dropped == 0 || dropped == 100 {
+		t.Fatalf("expected some but not all values to be dropped while training, but %v were", dropped)
+	}
+}
+
+func TestAugmentationLayers(t *testing.T) {
+	x := T.New(T.WithShape(4, 2, 4, 5), T.WithBacking(T.Range(T.Float64, 1, 161)))
+	// fitOutput builds a model with the layer made by makeLayer, checks what it does while predicting, and returns its output from FitBatch
+	fitOutput := func(makeLayer func(m *Model) interface{ MustAttach(*G.Node) *G.Node }, predicted T.Tensor) []float64 {
+		model := NewModel()
+		inputs := Input(model, "input", T.Float64, 4, 2, 4, 5).Node()
+		outputs := makeLayer(model).MustAttach(inputs)
+		model.MustBuild(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+		ys := model.MustPredictBatch(NamedTs{"x": x})
+		if !ys["yp"].(*T.Dense).Eq(predicted) {
+			t.Fatalf("wrong output while predicting, got %v", ys["yp"])
+		}
+		model.MustFitBatch(NamedTs{"x": x}, NamedTs{"yt": predicted}, G.NewVanillaSolver())
+		return append([]float64{}, (*model.OutputValues["yp"]).Data().([]float64)...)
+	}
+	centreCropData := []float64{}
+	for n := 0; n < 8; n++ {
+		for row := 1; row < 3; row++ {
+			for col := 1; col < 4; col++ {
+				centreCropData = append(centreCropData, float64(n*20+row*5+col+1))
+			}
+		}
+	}
+	centreCrop := T.New(T.WithShape(4, 2, 2, 3), T.WithBacking(centreCropData))
+	tests := []struct {
+		name      string
+		makeLayer func(m *Model, seed int64) interface{ MustAttach(*G.Node) *G.Node }
+		predicted T.Tensor
+	}{
+		{"gaussiannoise", func(m *Model, seed int64) interface{ MustAttach(*G.Node) *G.Node } {
+			l := GaussianNoise(m, "aug", 0.5)
+			l.Seed = seed
+			return l
+		}, x},
+		{"gaussiandropout", func(m *Model, seed int64) interface{ MustAttach(*G.Node) *G.Node } {
+			l := GaussianDropout(m, "aug", 0.5)
+			l.Seed = seed
+			return l
+		}, x},
+		{"randomflip", func(m *Model, seed int64) interface{ MustAttach(*G.Node) *G.Node } {
+			l := RandomFlip(m, "aug", "horizontal_and_vertical")
+			l.Seed = seed
+			return l
+		}, x},
+		{"randomcrop", func(m *Model, seed int64) interface{ MustAttach(*G.Node) *G.Node } {
+			l := RandomCrop(m, "aug", 2, 3)
+			l.Seed = seed
+			return l
+		}, centreCrop},
+		{"randomtranslation", func(m *Model, seed int64) interface{ MustAttach(*G.Node) *G.Node } {
+			l := RandomTranslation(m, "aug", 0.5, 0.5)
+			l.Seed = seed
+			return l
+		}, x},
+	}
+	for _, test := range tests {
+		withSeed := func(seed int64) func(m *Model) interface{ MustAttach(*G.Node) *G.Node } {
+			return func(m *Model) interface{ MustAttach(*G.Node) *G.Node } { return test.makeLayer(m, seed) }
+		}
+		a, b, c := fitOutput(withSeed(1), test.predicted), fitOutput(withSeed(1), test.predicted), fitOutput(withSeed(2), test.predicted)
+		if fmt.Sprint(a) != fmt.Sprint(b) {
+			t.Fatalf("%s: expected the same seed to give the same output", test.name)
+		}
+		if fmt.Sprint(a) == fmt.Sprint(test.predicted.Data()) || fmt.Sprint(a) == fmt.Sprint(c) {
+			t.Fatalf("%s: expected the output to be randomly changed while training", test.name)
+		}
+	}
+
+	// Flips and crops should only move values around
+	model := NewModel()
+	inputs := Input(model, "input", T.Float64, 4, 2, 4, 5).Node()
+	flipped := RandomFlip(model, "flip", "horizontal").MustAttach(inputs)
+	model.MustBuild(WithInput("x", inputs), WithOutput("yp", flipped), WithLoss(MSELoss("yt", flipped)))
+	model.MustFitBatch(NamedTs{"x": x}, NamedTs{"yt": x}, G.NewVanillaSolver())
+	out := (*model.OutputValues["yp"]).Data().([]float64)
+	for row := 0; row < len(out); row += 5 {
+		first := out[row]
+		if first != float64(row+1) && first != float64(row+5) {
+			t.Fatalf("expected each row to be flipped horizontally or not at all, got %v", out[row:row+5])
+		}
+	}
+
+	// Invalid settings are errors
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 1, 1, 3, 3).Node()
+	if _, err := GaussianNoise(model, "noise", -1).Attach(inputs); err == nil {
+		t.Fatal("expected an error with a negative stddev")
+	}
+	if _, err := GaussianDropout(model, "dropout", 1).Attach(inputs); err == nil {
+		t.Fatal("expected an error with a dropout rate of 1")
+	}
+	if _, err := RandomFlip(model, "flip", "diagonal").Attach(inputs); err == nil {
+		t.Fatal("expected an error with an unknown flip mode")
+	}
+	if _, err := RandomCrop(model, "crop", 4, 2).Attach(inputs); err == nil {
+		t.Fatal("expected an error cropping to more than the input size")
+	}
+	if _, err := RandomTranslation(model, "translation", 0.5, 1.5).Attach(inputs); err == nil {
+		t.Fatal("expected an error with a translation factor above 1")
+	}
+}
+
+func makeBatchNormModel() (*Model, error) {
+	model := NewModel()
+	namer := NewNamer("model")
+	inputs := Input(model, namer(), T.Float64, 4, 2).Node()
+	outputs, err := Dense(model, namer(), 3).Attach(inputs)
+	if err != nil {
+		return nil, err
+	}
+	bn := BatchNorm(model, namer())
+	bn.Momentum = 0.5
+	outputs, err = bn.Attach(outputs)
+	if err != nil {
+		return nil, err
+	}
+	err = model.Build(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func TestBatchNorm(t *testing.T) {
+	model, err := makeBatchNormModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	x, _ := loadXORXY()
+	y := T.New(T.WithShape(4, 3), T.WithBacking([]float64{0, 1, 2, 1, 2, 3, 2, 3, 4, 3, 4, 5}))
+	solver := G.NewAdamSolver(G.WithLearnRate(0.01))
+	if err := model.Fit(NamedTs{"x": x}, NamedTs{"yt": y}, solver, WithEpochs(20), WithVerbose(false)); err != nil {
+		t.Fatal(err)
+	}
+	params := model.GetParams()
+	if _, ok := params["model_3:running_mean"]; !ok {
+		t.Fatal("running mean was not included in the params")
+	}
+	yps, err := model.Predict(NamedTs{"x": x})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Predicting must use the running statistics, not the statistics of the batch (which would give beta for a single repeated sample)
+	xSame := T.New(T.WithShape(4, 2), T.WithBacking([]float64{1, 1, 1, 1, 1, 1, 1, 1}))
+	ypsSame, err := model.Predict(NamedTs{"x": xSame})
+	if err != nil {
+		t.Fatal(err)
+	}
+	weights, biases := params["model_2:weights"].Data().([]float64), params["model_2:biases"].Data().([]float64)
+	gamma, beta := params["model_3:gamma"].Data().([]float64), params["model_3:beta"].Data().([]float64)
+	runningMean, runningVar := params["model_3:running_mean"].Data().([]float64), params["model_3:running_var"].Data().([]float64)
+	for c := 0; c < 3; c++ {
+		dense := weights[c] + weights[3+c] + biases[c]
+		expected := gamma[c]*(dense-runningMean[c])/math.Sqrt(runningVar[c]+0.001) + beta[c]
+		for b := 0; b < 4; b++ {
+			if v, _ := ypsSame["yp"].At(b, c); math.Abs(v.(float64)-expected) > 1e-9 {
+				t.Fatalf("expected batch norm to use the running statistics and give %v for channel %v, got %v", expected, c, v)
+			}
+		}
+	}
+
+	buf := bytes.NewBuffer(make([]byte, 0))
+	if err := model.WriteParams(buf); err != nil {
+		t.Fatal(err)
+	}
+	model2, err := makeBatchNormModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model2.ReadParams(buf); err != nil {
+		t.Fatal(err)
+	}
+	yps2, err := model2.Predict(NamedTs{"x": x})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !yps["yp"].Eq(yps2["yp"]) {
+		t.Fatalf("Output tensors were not equal: \n%v and \n%v", yps["yp"], yps2["yp"])
+	}
+}
+
+func TestNormalisationLayers(t *testing.T) {
+	model := NewModel()
+	namer := NewNamer("model")
+	inputs := Input(model, namer(), T.Float64, 2, 4, 2, 2).Node()
+	lnOut, err := LayerNorm(model, namer()).Attach(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gnOut, err := GroupNorm(model, namer(), 2).Attach(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	inOut, err := InstanceNorm(model, namer()).Attach(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// MSELoss only works on 2D nodes, so each output is flattened for the loss
+	losses := []LossFunc{}
+	for i, n := range []*G.Node{lnOut, gnOut, inOut} {
+		flat, err := Reshape(model, namer(), T.Shape{2, 16}).Attach(n)
+		if err != nil {
+			t.Fatal(err)
+		}
+		losses = append(losses, MSELoss(fmt.Sprintf("yt%v", i), flat))
+	}
+	err = model.Build(WithInput("x", inputs), WithOutput("ln", lnOut), WithOutput("gn", gnOut), WithOutput("in", inOut), WithLoss(WeightedAdditiveLoss(losses, []float64{1, 1, 1})))
+	if err != nil {
+		t.Fatal(err)
+	}
+	xData := make([]float64, 32)
+	for i := range xData {
+		xData[i] = float64(i*i%7) - 3
+	}
+	yps, err := model.Predict(NamedTs{"x": T.New(T.WithShape(2, 4, 2, 2), T.WithBacking(xData))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Each group of this many consecutive values should have a mean of 0 and variance of 1
+	groupSizes := map[string]int{"ln": 2, "gn": 8, "in": 4}
+	for name, size := range groupSizes {
+		data := yps[name].Data().([]float64)
+		for start := 0; start < len(data); start += size {
+			mean, variance := 0.0, 0.0
+			for _, v := range data[start : start+size] {
+				mean += v / float64(size)
+			}
+			for _, v := range data[start : start+size] {
+				variance += (v - mean) * (v - mean) / float64(size)
+			}
+			if math.Abs(mean) > 1e-6 || (variance > 1e-6 && math.Abs(variance-1) > 0.01) {
+				t.Fatalf("%v output was not normalised, got mean %v and variance %v", name, mean, variance)
+			}
+		}
+	}
+
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 2, 4, 2, 2).Node()
+	if _, err := GroupNorm(model, "groupnorm", 3).Attach(inputs); err == nil {
+		t.Fatal("expected an error when the channels are not divisible by the groups")
+	}
+}
+
+func TestEmbedding(t *testing.T) {
+	model := NewModel()
+	namer := NewNamer("model")
+	inputs := Input(model, namer(), T.Int, 4, 3).Node()
+	emb := Embedding(model, namer(), 5, 2)
+	emb.PaddingIndex = 0
+	outputs, err := emb.Attach(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exactShapeEq(outputs.Shape(), T.Shape{4, 3, 2}) {
+		t.Fatal("wrong output shape: ", outputs.Shape())
+	}
+	flat, err := Reshape(model, namer(), T.Shape{4, 6}).Attach(outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = model.Build(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", flat)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := T.New(T.WithShape(4, 3), T.WithBacking([]int{1, 2, 0, 3, 0, 0, 4, 1, 2, 1, 1, 0}))
+	y := T.New(T.WithShape(4, 6), T.WithBacking([]float64{
+		1, 1, 2, 2, 0, 0,
+		3, 3, 0, 0, 0, 0,
+		4, 4, 1, 1, 2, 2,
+		1, 1, 1, 1, 0, 0,
+	}))
+	solver := G.NewAdamSolver(G.WithLearnRate(0.1))
+	if err := model.Fit(NamedTs{"x": x}, NamedTs{"yt": y}, solver, WithEpochs(200), WithVerbose(false)); err != nil {
+		t.Fatal(err)
+	}
+	table := model.GetParams()["model_2:embeddings"].Data().([]float64)
+	for i := 0; i < 5; i++ {
+		for j := 0; j < 2; j++ {
+			v := table[i*2+j]
+			if i == 0 && v != 0 {
+				t.Fatalf("padding row was not zero: %v", table[:2])
+			} else if i > 0 && math.Abs(v-float64(i)) > 0.05 {
+				t.Fatalf("row %v was not learned, expected %v but got %v", i, float64(i), table[i*2:i*2+2])
+			}
+		}
+	}
+	if _, err := Embedding(model, namer(), 5, 2).Attach(Input(model, namer(), T.Float64, 4, 3).Node()); err == nil {
+		t.Fatal("expected an error when the input is not an int")
+	}
+}
+
+func TestRecurrentLayers(t *testing.T) {
+	model := NewModel()
+	namer := NewNamer("model")
+	inputs := Input(model, namer(), T.Float64, 4, 3, 2).Node()
+	initialState := Input(model, namer(), T.Float64, 4, 5).Node()
+	rnn := SimpleRNN(model, namer(), 5)
+	rnn.ReturnSequences = true
+	outputs, err := rnn.AttachWithInitialStates(inputs, initialState)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exactShapeEq(outputs.Shape(), T.Shape{4, 3, 5}) {
+		t.Fatal("wrong output shape: ", outputs.Shape())
+	}
+	lstm := LSTM(model, namer(), 4)
+	lstm.ReturnSequences = true
+	outputs, err = lstm.Attach(outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	outputs, err = GRU(model, namer(), 1).Attach(outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exactShapeEq(outputs.Shape(), T.Shape{4, 1}) {
+		t.Fatal("wrong output shape: ", outputs.Shape())
+	}
+	err = model.Build(WithInput("x", inputs), WithInput("h", initialState), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := model.GetParams()["model_4:recurrent_kernel"]; !ok {
+		t.Fatal("recurrent kernel was not included in the params")
+	}
+	// The model should learn to output the mean of the first feature over the timesteps
+	x := T.New(T.WithShape(4, 3, 2), T.WithBacking([]float64{
+		0.1, 0, 0.2, 0, 0.3, 0,
+		-0.2, 1, -0.1, 1, 0.0, 1,
+		0.5, 0, 0.3, 1, 0.1, 0,
+		-0.3, 1, 0.3, 0, -0.3, 1,
+	}))
+	h := T.New(T.WithShape(4, 5), T.Of(T.Float64))
+	y := T.New(T.WithShape(4, 1), T.WithBacking([]float64{0.2, -0.1, 0.3, -0.1}))
+	solver := G.NewAdamSolver(G.WithLearnRate(0.01))
+	firstLoss, err := model.FitBatch(NamedTs{"x": x, "h": h}, NamedTs{"yt": y}, solver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Fit(NamedTs{"x": x, "h": h}, NamedTs{"yt": y}, solver, WithEpochs(200), WithVerbose(false)); err != nil {
+		t.Fatal(err)
+	}
+	lastLoss, err := model.FitBatch(NamedTs{"x": x, "h": h}, NamedTs{"yt": y}, solver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastLoss > firstLoss/10 {
+		t.Fatalf("loss did not decrease enough, started at %v and ended at %v", firstLoss, lastLoss)
+	}
+
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 4, 3, 4).Node()
+	initialState = Input(model, "state", T.Float64, 4, 5).Node()
+	if _, err := GRU(model, "gru", 3).AttachWithInitialStates(inputs, initialState); err == nil {
+		t.Fatal("expected an error when the initial state has the wrong shape")
+	}
+}
+
+func TestBiases(t *testing.T) {
+	makeModel := func(useBias bool) (*Model, *DenseLayer) {
+		model := NewModel()
+		inputs := Input(model, "input", T.Float64, 2, 2).Node()
+		dense := Dense(model, "dense", 3)
+		dense.UseBias = useBias
+		outputs := dense.MustAttach(inputs)
+		model.MustBuild(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+		return model, dense
+	}
+	x := T.New(T.WithShape(2, 2), T.WithBacking([]float64{1, 2, 3, 4}))
+
+	// Old checkpoints stored the biases as the last row of the weights
+	model, dense := makeModel(true)
+	if len(dense.Parameters()) != 2 || !exactShapeEq(dense.Biases.Shape(), T.Shape{3}) {
+		t.Fatalf("expected separate weights and biases, got %v", dense.Parameters())
+	}
+	oldParams := map[string]*T.Dense{"dense:weights": T.New(T.WithShape(3, 3), T.WithBacking([]float64{
+		1, 0, 1,
+		0, 1, 1,
+		10, 20, 30,
+	}))}
+	if err := model.SetParams(oldParams); err != nil {
+		t.Fatal(err)
+	}
+	ys, err := model.PredictBatch(NamedTs{"x": x})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ys["yp"].Data()) != "[11 22 33 13 24 37]" {
+		t.Fatalf("wrong output after loading old checkpoint: %v", ys["yp"].Data())
+	}
+	if !exactShapeEq(model.GetParams()["dense:weights"].Shape(), T.Shape{2, 3}) {
+		t.Fatal("old checkpoint changed the shape of the weights")
+	}
+
+	// Without biases, old checkpoints can only be loaded if they do not have any biases
+	model, dense = makeModel(false)
+	if len(dense.Parameters()) != 1 {
+		t.Fatalf("expected no biases, got %v", dense.Parameters())
+	}
+	if err := model.SetParams(oldParams); err == nil {
+		t.Fatal("expected an error loading biases into a layer without biases")
+	}
+
+	// Convolutional layers should have a bias for each kernel
+	model = NewModel()
+	inputs := Input(model, "input", T.Float64, 2, 3, 4, 4).Node()
+	conv := SimpleConv2D(model, "conv", 3, 5)
+	conv.MustAttach(inputs)
+	if !exactShapeEq(conv.Biases.Shape(), T.Shape{5}) || len(conv.Parameters()) != 2 {
+		t.Fatalf("expected a bias for each kernel, got %v", conv.Parameters())
+	}
+}
+
+func TestConv2DTranspose(t *testing.T) {
+	model := NewModel()
+	namer := NewNamer("model")
+	inputs := Input(model, namer(), T.Float64, 2, 1, 4, 4).Node()
+	outputs := SimpleMaxPooling2D(model, namer(), 2).MustAttach(inputs)
+	outputs, err := Conv2DTranspose(model, namer(), []int{3, 3}, []int{2, 2}, "same", 1).Attach(outputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exactShapeEq(outputs.Shape(), T.Shape{2, 1, 4, 4}) {
+		t.Fatal("wrong output shape for same padding: ", outputs.Shape())
+	}
+	err = model.Build(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(model.Summary(), "(2, 1, 4, 4)") {
+		t.Fatalf("summary did not show the output shape: \n%v", model.Summary())
+	}
+	// The model should learn to reconstruct blocky images from the max pooled versions
+	x := T.New(T.WithShape(2, 1, 4, 4), T.WithBacking([]float64{
+		1, 1, 0, 0,
+		1, 1, 0, 0,
+		0, 0, 1, 1,
+		0, 0, 1, 1,
+		0, 0, 0, 0,
+		0, 0, 0, 0,
+		1, 1, 0, 0,
+		1, 1, 0, 0,
+	}))
+	solver := G.NewAdamSolver(G.WithLearnRate(0.05))
+	firstLoss, err := model.FitBatch(NamedTs{"x": x}, NamedTs{"yt": x}, solver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Fit(NamedTs{"x": x}, NamedTs{"yt": x}, solver, WithEpochs(200), WithVerbose(false)); err != nil {
+		t.Fatal(err)
+	}
+	lastLoss, err := model.FitBatch(NamedTs{"x": x}, NamedTs{"yt": x}, solver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastLoss > firstLoss/10 {
+		t.Fatalf("loss did not decrease enough, started at %v and ended at %v", firstLoss, lastLoss)
+	}
+
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 2, 1, 4, 4).Node()
+	validOutputs, err := Conv2DTranspose(model, "convt_valid", []int{3, 3}, []int{2, 2}, "valid", 1).Attach(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exactShapeEq(validOutputs.Shape(), T.Shape{2, 1, 9, 9}) {
+		t.Fatal("wrong output shape for valid padding: ", validOutputs.Shape())
+	}
+	if _, err := Conv2DTranspose(model, "convt_full", []int{3, 3}, []int{2, 2}, "full", 1).Attach(inputs); err == nil {
+		t.Fatal("expected an error for an unknown padding")
+	}
+}
+
+func TestConv1DAnd3D(t *testing.T) {
+	// 1D pooling should give the same result as 2D pooling on an image that is one pixel wide
+	model := NewModel()
+	namer := NewNamer("model")
+	inputs1D := Input(model, namer(), T.Float64, 2, 1, 5).Node()
+	inputs2D := Input(model, namer(), T.Float64, 2, 1, 5, 1).Node()
+	outputs1D := MaxPooling1D(model, namer(), 2, 2, "same").MustAttach(inputs1D)
+	outputs2D := MaxPooling2D(model, namer(), []int{2, 1}, []int{2, 1}, "same").MustAttach(inputs2D)
+	if !exactShapeEq(outputs1D.Shape(), T.Shape{2, 1, 3}) {
+		t.Fatal("wrong output shape for same padding: ", outputs1D.Shape())
+	}
+	err := model.Build(WithInput("x1", inputs1D), WithInput("x2", inputs2D), WithOutput("y1", outputs1D), WithOutput("y2", outputs2D), WithLoss(MSELoss("yt", outputs1D)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	xData := []float64{3, -1, 4, 1, -5, -9, 2, -6, 5, 3}
+	x1 := T.New(T.WithShape(2, 1, 5), T.WithBacking(xData))
+	x2 := T.New(T.WithShape(2, 1, 5, 1), T.WithBacking(xData))
+	ys, err := model.PredictBatch(NamedTs{"x1": x1, "x2": x2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fmt.Sprint(ys["y1"].Data()) != fmt.Sprint(ys["y2"].Data()) {
+		t.Fatalf("1D pooling gave %v but 2D pooling gave %v", ys["y1"].Data(), ys["y2"].Data())
+	}
+
+	// Check the shapes of the convolutions
+	model = NewModel()
+	inputs := Input(model, namer(), T.Float64, 2, 3, 8).Node()
+	outputs, err := Conv1D(model, namer(), 3, 2, "valid", 4).Attach(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exactShapeEq(outputs.Shape(), T.Shape{2, 4, 3}) {
+		t.Fatal("wrong output shape for 1D valid padding: ", outputs.Shape())
+	}
+	outputs, err = SimpleConv1D(model, namer(), 3, 4).Attach(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exactShapeEq(outputs.Shape(), T.Shape{2, 4, 8}) {
+		t.Fatal("wrong output shape for 1D same padding: ", outputs.Shape())
+	}
+	if _, err := Conv3D(model, namer(), []int{3, 3}, []int{1, 1}, "same", 1).Attach(inputs); err == nil {
+		t.Fatal("expected an error for a 3D convolution on a 1D input")
+	}
+	if _, err := Conv1D(model, namer(), 3, 0, "same", 4).Attach(inputs); err == nil {
+		t.Fatal("expected an error for a 1D convolution with a stride of 0")
+	}
+	if _, err := Conv1D(model, namer(), 0, 1, "same", 4).Attach(inputs); err == nil {
+		t.Fatal("expected an error for a 1D convolution with a kernel size of 0")
+	}
+	if _, err := MaxPooling1D(model, namer(), 2, 0, "same").Attach(inputs); err == nil {
+		t.Fatal("expected an error for 1D pooling with a stride of 0")
+	}
+	model = NewModel()
+	inputs = Input(model, namer(), T.Float64, 2, 1, 4, 4, 4).Node()
+	if _, err := Conv3D(model, namer(), []int{3, 3, 3}, []int{1, 0, 1}, "same", 1).Attach(inputs); err == nil {
+		t.Fatal("expected an error for a 3D convolution with a stride of 0")
+	}
+	if _, err := MaxPooling3D(model, namer(), []int{2, 2, 2}, []int{2, 2, 0}, "valid").Attach(inputs); err == nil {
+		t.Fatal("expected an error for 3D pooling with a stride of 0")
+	}
+	if _, err := MaxPooling3D(model, namer(), []int{0, 2, 2}, []int{2, 2, 2}, "valid").Attach(inputs); err == nil {
+		t.Fatal("expected an error for 3D pooling with a pool size of 0")
+	}
+
+	// A small 3D network should be able to learn to tell which half of a volume is filled
+	model = NewModel()
+	inputs = Input(model, namer(), T.Float64, 2, 1, 4, 4, 4).Node()
+	outputs = SimpleConv3D(model, namer(), 3, 2).MustAttach(inputs)
+	outputs = SimpleMaxPooling3D(model, namer(), 2).MustAttach(outputs)
+	outputs = Conv3D(model, namer(), []int{2, 2, 2}, []int{1, 1, 1}, "valid", 1).MustAttach(outputs)
+	if !exactShapeEq(outputs.Shape(), T.Shape{2, 1, 1, 1, 1}) {
+		t.Fatal("wrong output shape for 3D network: ", outputs.Shape())
+	}
+	outputs = Reshape(model, namer(), T.Shape{2, 1}).MustAttach(outputs)
+	outputs = Sigmoid(model, namer()).MustAttach(outputs)
+	err = model.Build(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(BCELoss("yt", outputs)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	volumes := make([]float64, 2*64)
+	for i := 0; i < 32; i++ {
+		volumes[i] = 1
+		volumes[96+i] = 1
+	}
+	x := T.New(T.WithShape(2, 1, 4, 4, 4), T.WithBacking(volumes))
+	y := T.New(T.WithShape(2, 1), T.WithBacking([]float64{0, 1}))
+	solver := G.NewAdamSolver(G.WithLearnRate(0.05))
+	firstLoss, err := model.FitBatch(NamedTs{"x": x}, NamedTs{"yt": y}, solver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Fit(NamedTs{"x": x}, NamedTs{"yt": y}, solver, WithEpochs(100), WithVerbose(false)); err != nil {
+		t.Fatal(err)
+	}
+	lastLoss, err := model.FitBatch(NamedTs{"x": x}, NamedTs{"yt": y}, solver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastLoss > firstLoss/10 {
+		t.Fatalf("loss did not decrease enough, started at %v and ended at %v", firstLoss, lastLoss)
+	}
+}
+
+func TestDilatedAndDepthwiseConv(t *testing.T) {
+	// A dilated 3x3 kernel covers a 5x5 area, and "same" padding should keep the shape
+	model := NewModel()
+	inputs := Input(model, "input", T.Float64, 1, 1, 5, 5).Node()
+	conv := SimpleConv2D(model, "conv", 3, 1)
+	conv.Dilation = []int{2, 2}
+	conv.UseBias = false
+	conv.Initializer = Ones()
+	outputs := conv.MustAttach(inputs)
+	model.MustBuild(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+	ys := model.MustPredictBatch(NamedTs{"x": T.New(T.WithShape(1, 1, 5, 5), T.WithBacking(T.Ones(T.Float64, 25).Data()))})
+	if !exactShapeEq(ys["yp"].Shape(), T.Shape{1, 1, 5, 5}) {
+		t.Fatalf("expected dilated same padding to keep the shape, got %v", ys["yp"].Shape())
+	}
+	if v, _ := ys["yp"].At(0, 0, 0, 0); v.(float64) != 4 {
+		t.Fatalf("expected the dilated kernel to see 4 inputs in the corner, got %v", v)
+	}
+	if v, _ := ys["yp"].At(0, 0, 2, 2); v.(float64) != 9 {
+		t.Fatalf("expected the dilated kernel to see 9 inputs in the centre, got %v", v)
+	}
+
+	// Each input channel should only be convolved with its own kernels
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 1, 2, 3, 3).Node()
+	depthwise := DepthwiseConv2D(model, "depthwise", []int{3, 3}, []int{1, 1}, "valid", 2)
+	outputs = depthwise.MustAttach(inputs)
+	model.MustBuild(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+	if !exactShapeEq(depthwise.Kernels.Shape(), T.Shape{4, 1, 3, 3}) || !exactShapeEq(outputs.Shape(), T.Shape{1, 4, 1, 1}) {
+		t.Fatalf("wrong depthwise shapes: kernels %v, output %v", depthwise.Kernels.Shape(), outputs.Shape())
+	}
+	kernels := make([]float64, 36)
+	for i := range kernels {
+		kernels[i] = float64(i/9 + 1)
+	}
+	model.MustSetParams(map[string]*T.Dense{
+		"depthwise:kernels": T.New(T.WithShape(4, 1, 3, 3), T.WithBacking(kernels)),
+		"depthwise:biases":  T.New(T.WithShape(4), T.WithBacking([]float64{0, 0, 0, 1})),
+	})
+	x := make([]float64, 18)
+	for i := 9; i < 18; i++ {
+		x[i] = 10
+	}
+	for i := 0; i < 9; i++ {
+		x[i] = 1
+	}
+	ys = model.MustPredictBatch(NamedTs{"x": T.New(T.WithShape(1, 2, 3, 3), T.WithBacking(x))})
+	if fmt.Sprint(ys["yp"].Data()) != "[9 18 270 361]" {
+		t.Fatalf("wrong depthwise output: %v", ys["yp"].Data())
+	}
+
+	// Separable convolutions are a depthwise convolution followed by a pointwise one
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 2, 3, 6, 6).Node()
+	separable := SimpleSeparableConv2D(model, "separable", 3, 8)
+	separable.Dilation = []int{2, 2}
+	outputs = separable.MustAttach(inputs)
+	model.MustBuild(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+	if !exactShapeEq(outputs.Shape(), T.Shape{2, 8, 6, 6}) {
+		t.Fatalf("wrong separable output shape %v", outputs.Shape())
+	}
+	numParams := 0
+	for _, p := range separable.Parameters() {
+		numParams += p.Shape().TotalSize()
+	}
+	if numParams != 3*9+8*3+8 {
+		t.Fatalf("expected %v separable parameters but got %v", 3*9+8*3+8, numParams)
+	}
+
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 1, 2, 4, 4).Node()
+	if _, err := DepthwiseConv2D(model, "depthwise_stride", []int{3, 3}, []int{0, 0}, "same", 1).Attach(inputs); err == nil {
+		t.Fatal("expected an error for a depthwise stride of 0")
+	}
+	depthwise = DepthwiseConv2D(model, "depthwise_dilation", []int{3, 3}, []int{1, 1}, "same", 1)
+	depthwise.Dilation = []int{0, 1}
+	if _, err := depthwise.Attach(inputs); err == nil {
+		t.Fatal("expected an error for a depthwise dilation of 0")
+	}
+	if _, err := DepthwiseConv2D(model, "depthwise_multiplier", []int{3, 3}, []int{1, 1}, "same", 0).Attach(inputs); err == nil {
+		t.Fatal("expected an error for a depth multiplier of 0")
+	}
+	if _, err := SeparableConv2D(model, "separable_stride", []int{3, 3}, []int{0, 0}, "same", 2).Attach(inputs); err == nil {
+		t.Fatal("expected an error for a separable stride of 0")
+	}
+	separable = SimpleSeparableConv2D(model, "separable_multiplier", 3, 2)
+	separable.DepthMultiplier = -1
+	if _, err := separable.Attach(inputs); err == nil {
+		t.Fatal("expected an error for a negative depth multiplier")
+	}
+	separable = SimpleSeparableConv2D(model, "separable_dilation", 3, 2)
+	separable.Dilation = []int{1, 0}
+	if _, err := separable.Attach(inputs); err == nil {
+		t.Fatal("expected an error for a separable dilation of 0")
+	}
+	conv = SimpleConv2D(model, "conv_dilation", 3, 1)
+	conv.Dilation = []int{0, 0}
+	if _, err := conv.Attach(inputs); err == nil {
+		t.Fatal("expected an error for a dilation of 0")
+	}
+}
+
+func TestPoolingLayers(t *testing.T) {
+	model := NewModel()
+	namer := NewNamer("model")
+	inputs := Input(model, namer(), T.Float64, 1, 1, 4, 4).Node()
+	rowInputs := Input(model, namer(), T.Float64, 1, 1, 1, 3).Node()
+	avgValid := AveragePooling2D(model, namer(), []int{2, 2}, []int{2, 2}, "valid").MustAttach(inputs)
+	avgSame := AveragePooling2D(model, namer(), []int{1, 3}, []int{1, 1}, "same").MustAttach(rowInputs)
+	globalAvg := GlobalAveragePooling2D(model, namer()).MustAttach(inputs)
+	globalMax := GlobalMaxPooling2D(model, namer()).MustAttach(inputs)
+	if !exactShapeEq(globalAvg.Shape(), T.Shape{1, 1}) || !exactShapeEq(globalMax.Shape(), T.Shape{1, 1}) {
+		t.Fatal("wrong output shape for global pooling: ", globalAvg.Shape(), globalMax.Shape())
+	}
+	err := model.Build(
+		WithInput("x", inputs), WithInput("row", rowInputs),
+		WithOutput("avg_valid", avgValid), WithOutput("avg_same", avgSame), WithOutput("global_avg", globalAvg), WithOutput("global_max", globalMax),
+		WithLoss(MSELoss("yt", globalAvg)),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := T.New(T.WithShape(1, 1, 4, 4), T.WithBacking(T.Range(T.Float64, 1, 17)))
+	row := T.New(T.WithShape(1, 1, 1, 3), T.WithBacking([]float64{1, 2, 3}))
+	ys, err := model.PredictBatch(NamedTs{"x": x, "row": row})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The padding should not count towards the average
+	expected := map[string][]float64{
+		"avg_valid":  {3.5, 5.5, 11.5, 13.5},
+		"avg_same":   {1.5, 2, 2.5},
+		"global_avg": {8.5},
+		"global_max": {16},
+	}
+	for name, e := range expected {
+		if fmt.Sprint(ys[name].Data()) != fmt.Sprint(e) {
+			t.Fatalf("expected %v to be %v but got %v", name, e, ys[name].Data())
+		}
+	}
+
+	// Global pooling should work with any image size
+	model = NewModel()
+	inputs = Input(model, namer(), T.Float64, 2, 3, 5, 7).Node()
+	outputs, err := GlobalAveragePooling2D(model, namer()).Attach(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exactShapeEq(outputs.Shape(), T.Shape{2, 3}) {
+		t.Fatal("wrong output shape for global pooling: ", outputs.Shape())
+	}
+	if _, err := AveragePooling2D(model, namer(), []int{2, 2}, []int{2, 2}, "full").Attach(inputs); err == nil {
+		t.Fatal("expected an error for an unknown padding")
+	}
+	if _, err := AveragePooling2D(model, namer(), []int{2, 2}, []int{0, 0}, "valid").Attach(inputs); err == nil {
+		t.Fatal("expected an error for a stride of 0")
+	}
+	if _, err := AveragePooling2D(model, namer(), []int{0, 2}, []int{2, 2}, "valid").Attach(inputs); err == nil {
+		t.Fatal("expected an error for a pool size of 0")
+	}
+}
+
+func TestFlattenAndReshape(t *testing.T) {
+	model := NewModel()
+	namer := NewNamer("model")
+	inputs := Input(model, namer(), T.Float64, 3, 2, 2, 5).Node()
+	flat, err := Flatten(model, namer()).Attach(inputs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exactShapeEq(flat.Shape(), T.Shape{3, 20}) {
+		t.Fatal("wrong output shape for flatten: ", flat.Shape())
+	}
+	unflat, err := Reshape(model, namer(), T.Shape{3, -1, 5}).Attach(flat)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !exactShapeEq(unflat.Shape(), T.Shape{3, 4, 5}) {
+		t.Fatal("wrong output shape for reshape with an inferred dim: ", unflat.Shape())
+	}
+	err = model.Build(WithInput("x", inputs), WithOutput("flat", flat), WithOutput("unflat", unflat), WithLoss(MSELoss("yt", flat)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	x := T.New(T.WithShape(3, 2, 2, 5), T.WithBacking(T.Range(T.Float64, 0, 60)))
+	ys, err := model.PredictBatch(NamedTs{"x": x})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"flat", "unflat"} {
+		if fmt.Sprint(ys[name].Data()) != fmt.Sprint(x.Data()) {
+			t.Fatalf("%v changed the order of the data: %v", name, ys[name].Data())
+		}
+	}
+
+	model = NewModel()
+	namer = NewNamer("scratch")
+	inputs = Input(model, namer(), T.Float64, 3, 20).Node()
+	for _, shape := range []T.Shape{{-1, -1}, {7, -1}, {0, 60}} {
+		if _, err := Reshape(model, namer(), shape).Attach(inputs); err == nil {
+			t.Fatalf("expected an error when reshaping %v to %v", inputs.Shape(), shape)
+		}
+	}
+}
+
+func TestUpSampling2D(t *testing.T) {
+	model := NewModel()
+	namer := NewNamer("model")
+	inputs := Input(model, namer(), T.Float64, 1, 1, 2, 2).Node()
+	nearest := SimpleUpSampling2D(model, namer(), 2).MustAttach(inputs)
+	bilinear := UpSampling2D(model, namer(), []int{2, 2}, "bilinear").MustAttach(inputs)
+	err := model.Build(WithInput("x", inputs), WithOutput("nearest", nearest), WithOutput("bilinear", bilinear), WithLoss(MSELoss("yt", bilinear)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	yps, err := model.Predict(NamedTs{"x": T.New(T.WithShape(1, 1, 2, 2), T.WithBacking([]float64{1, 2, 3, 4}))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string][]float64{
+		"nearest": {
+			1, 1, 2, 2,
+			1, 1, 2, 2,
+			3, 3, 4, 4,
+			3, 3, 4, 4,
+		},
+		"bilinear": {
+			1, 1.25, 1.75, 2,
+			1.5, 1.75, 2.25, 2.5,
+			2.5, 2.75, 3.25, 3.5,
+			3, 3.25, 3.75, 4,
+		},
+	}
+	for name, exp := range expected {
+		if !yps[name].Eq(T.New(T.WithShape(1, 1, 4, 4), T.WithBacking(exp))) {
+			t.Fatalf("wrong %v output: \n%v", name, yps[name])
+		}
+	}
+
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 1, 1, 2, 2).Node()
+	if _, err := UpSampling2D(model, "upsampling", []int{2, 2}, "bicubic").Attach(inputs); err == nil {
+		t.Fatal("expected an error for an unknown interpolation")
+	}
+}
+
+func TestPaddingAndCropping(t *testing.T) {
+	model := NewModel()
+	inputs := Input(model, "input", T.Float64, 1, 1, 2, 3).Node()
+	padded := ZeroPadding2D(model, "pad", 1, 0, 0, 2).MustAttach(inputs)
+	outputs := Cropping2D(model, "crop", 1, 0, 0, 2).MustAttach(padded)
+	model.MustBuild(WithInput("x", inputs), WithOutput("padded", padded), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+	x := T.New(T.WithShape(1, 1, 2, 3), T.WithBacking([]float64{1, 2, 3, 4, 5, 6}))
+	ys := model.MustPredictBatch(NamedTs{"x": x})
+	if !exactShapeEq(ys["padded"].Shape(), T.Shape{1, 1, 3, 5}) || fmt.Sprint(ys["padded"].Data()) != "[0 0 0 0 0 1 2 3 0 0 4 5 6 0 0]" {
+		t.Fatalf("wrong padding output: %v", ys["padded"])
+	}
+	if !ys["yp"].(*T.Dense).Eq(x) {
+		t.Fatalf("expected cropping to undo the padding, got %v", ys["yp"])
+	}
+
+	// Both should be able to be trained through
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 2, 1, 4, 4).Node()
+	outputs = ZeroPadding2D(model, "pad", 1).MustAttach(inputs)
+	outputs = SimpleConv2D(model, "conv", 3, 2).MustAttach(outputs)
+	outputs = Cropping2D(model, "crop", 2, 1).MustAttach(outputs)
+	model.MustBuild(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+	if !exactShapeEq(outputs.Shape(), T.Shape{2, 2, 2, 4}) {
+		t.Fatalf("wrong output shape %v", outputs.Shape())
+	}
+	model.MustFitBatch(NamedTs{"x": T.New(T.WithShape(2, 1, 4, 4), T.Of(T.Float64))}, NamedTs{"yt": T.New(T.WithShape(2, 2, 2, 4), T.Of(T.Float64))}, G.NewVanillaSolver())
+
+	// Crops that remove the whole image, and negative amounts, are errors
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 1, 1, 3, 3).Node()
+	if _, err := Cropping2D(model, "crop", 2).Attach(inputs); err == nil {
+		t.Fatal("expected an error cropping more than the input size")
+	}
+	if _, err := Cropping2D(model, "crop2", 0, 3).Attach(inputs); err == nil {
+		t.Fatal("expected an error cropping more than the input size")
+	}
+	if _, err := ZeroPadding2D(model, "pad", -1).Attach(inputs); err == nil {
+		t.Fatal("expected an error with negative padding")
+	}
+	if _, err := ZeroPadding2D(model, "pad2", 1, 2, 3).Attach(inputs); err == nil {
+		t.Fatal("expected an error with three padding amounts")
+	}
+}
+
+func TestMergeLayers(t *testing.T) {
+	model := NewModel()
+	namer := NewNamer("model")
+	a := Input(model, namer(), T.Float64, 2, 2).Node()
+	b := Input(model, namer(), T.Float64, 2, 2).Node()
+	outputs := map[string]*G.Node{
+		"add":      Add(model, namer()).MustAttach(a, b),
+		"subtract": Subtract(model, namer()).MustAttach(a, b),
+		"multiply": Multiply(model, namer()).MustAttach(a, b),
+		"average":  Average(model, namer()).MustAttach(a, b, b),
+		"concat":   Concatenate(model, namer(), 1).MustAttach(a, b),
+	}
+	// Check the errors on a separate model, as the mismatched input should not be part of the real one
+	badModel := NewModel()
+	x1 := Input(badModel, namer(), T.Float64, 2, 2).Node()
+	x2 := Input(badModel, namer(), T.Float64, 2, 3).Node()
+	if _, err := Add(badModel, namer()).Attach(x1, x1, x2); err == nil || !strings.Contains(err.Error(), "input 2") {
+		t.Fatalf("expected an error about input 2, got %v", err)
+	}
+	if _, err := Concatenate(badModel, namer(), 0).Attach(x1, x2); err == nil || !strings.Contains(err.Error(), "input 1") {
+		t.Fatalf("expected an error about input 1, got %v", err)
+	}
+	opts := []BuildOpts{WithInput("a", a), WithInput("b", b), WithLoss(MSELoss("yt", outputs["concat"]))}
+	for name, n := range outputs {
+		opts = append(opts, WithOutput(name, n))
+	}
+	if err := model.Build(opts...); err != nil {
+		t.Fatal(err)
+	}
+	yps, err := model.Predict(NamedTs{
+		"a": T.New(T.WithShape(2, 2), T.WithBacking([]float64{1, 2, 3, 4})),
+		"b": T.New(T.WithShape(2, 2), T.WithBacking([]float64{4, 3, 2, 1})),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := map[string]*T.Dense{
+		"add":      T.New(T.WithShape(2, 2), T.WithBacking([]float64{5, 5, 5, 5})),
+		"subtract": T.New(T.WithShape(2, 2), T.WithBacking([]float64{-3, -1, 1, 3})),
+		"multiply": T.New(T.WithShape(2, 2), T.WithBacking([]float64{4, 6, 6, 4})),
+		"average":  T.New(T.WithShape(2, 2), T.WithBacking([]float64{3, 8.0 / 3, 7.0 / 3, 2})),
+		"concat":   T.New(T.WithShape(2, 4), T.WithBacking([]float64{1, 2, 4, 3, 3, 4, 2, 1})),
+	}
+	for name, exp := range expected {
+		got := yps[name].Data().([]float64)
+		for i, v := range exp.Data().([]float64) {
+			if math.Abs(got[i]-v) > 1e-9 {
+				t.Fatalf("wrong %v output: \n%v", name, yps[name])
+			}
+		}
+	}
+}
+
+func TestLambda(t *testing.T) {
+	model := NewModel()
+	a := Input(model, "a", T.Float64, 2, 3).Node()
+	b := Input(model, "b", T.Float64, 2, 3).Node()
+	sum := Lambda(model, "sum", func(xs ...*G.Node) (*G.Node, error) { return G.Add(xs[0], xs[1]) }).MustAttach(a, b)
+	var weights *G.Node
+	matmul := Lambda(model, "matmul", func(xs ...*G.Node) (*G.Node, error) { return G.Mul(xs[0], weights) })
+	weights = matmul.MustAddParameter("weights", T.Float64, []int{3, 2}, Ones())
+	if _, err := matmul.AddParameter("weights", T.Float64, []int{3, 2}, nil); err == nil {
+		t.Fatal("expected an error adding a parameter with the same name twice")
+	}
+	outputs := matmul.MustAttach(sum)
+	model.MustBuild(WithInput("a", a), WithInput("b", b), WithOutput("yp", outputs), WithLoss(MSELoss("yt", outputs)))
+
+	if !strings.Contains(model.Summary(), "sum::lambda") || !strings.Contains(model.Summary(), "matmul::lambda") {
+		t.Fatalf("expected lambda layers in the summary, got\n%v", model.Summary())
+	}
+	if len(model.Trainables()) != 1 || model.Trainables()[0] != weights {
+		t.Fatalf("expected the lambda parameter to be trainable, got %v", model.Trainables())
+	}
+
+	xa := T.New(T.WithShape(2, 3), T.WithBacking([]float64{1, 2, 3, 4, 5, 6}))
+	xb := T.New(T.WithShape(2, 3), T.WithBacking([]float64{1, 1, 1, 2, 2, 2}))
+	ys := model.MustPredictBatch(NamedTs{"a": xa, "b": xb})
+	if fmt.Sprint(ys["yp"].Data()) != "[9 9 21 21]" {
+		t.Fatalf("wrong lambda output: %v", ys["yp"].Data())
+	}
+	yt := T.New(T.WithShape(2, 2), T.WithBacking([]float64{0, 0, 0, 0}))
+	model.MustFitBatch(NamedTs{"a": xa, "b": xb}, NamedTs{"yt": yt}, G.NewVanillaSolver(G.WithLearnRate(0.001)))
+	if model.GetParams()["matmul:weights"].Data().([]float64)[0] >= 1 {
+		t.Fatal("expected the lambda parameter to be trained")
+	}
+}
+
+func makeAttentionModel() (*Model, error) {
+	model := NewModel()
+	namer := NewNamer("model")
+	query := Input(model, namer(), T.Float64, 2, 3, 4).Node()
+	keyValue := Input(model, namer(), T.Float64, 2, 3, 4).Node()
+	mask := Input(model, namer(), T.Float64, 2, 3, 3).Node()
+	attended, err := MultiHeadAttention(model, namer(), 2, 3).Attach(query, keyValue, keyValue, mask)
+	if err != nil {
+		return nil, err
+	}
+	encoded, err := TransformerEncoder(model, namer(), 2, 3, 8).Attach(attended, mask)
+	if err != nil {
+		return nil, err
+	}
+	flat, err := Reshape(model, namer(), T.Shape{2, 12}).Attach(encoded)
+	if err != nil {
+		return nil, err
+	}
+	err = model.Build(WithInput("q", query), WithInput("kv", keyValue), WithInput("mask", mask), WithOutput("yp", encoded), WithLoss(MSELoss("yt", flat)))
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+func TestMultiHeadAttention(t *testing.T) {
+	model, err := makeAttentionModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(model.Layers[3].Parameters()) != 8 {
+		t.Fatalf("expected 8 attention parameters but got %v", len(model.Layers[3].Parameters()))
+	}
+	data := make([]float64, 24)
+	for i := range data {
+		data[i] = math.Sin(float64(i))
+	}
+	q := T.New(T.WithShape(2, 3, 4), T.WithBacking(data))
+	// The last key is masked out for every query
+	mask := T.New(T.WithShape(2, 3, 3), T.WithBacking([]float64{
+		1, 1, 0, 1, 1, 0, 1, 1, 0,
+		1, 1, 0, 1, 1, 0, 1, 1, 0,
+	}))
+	kv := q.Clone().(*T.Dense)
+	yps, err := model.Predict(NamedTs{"q": q, "kv": kv, "mask": mask})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Changing the masked key should not change the output
+	kv2 := kv.Clone().(*T.Dense)
+	for _, b := range []int{0, 1} {
+		for f := 0; f < 4; f++ {
+			kv2.SetAt(float64(10), b, 2, f)
+		}
+	}
+	yps2, err := model.Predict(NamedTs{"q": q, "kv": kv2, "mask": mask})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, v := range yps["yp"].Data().([]float64) {
+		if math.Abs(v-yps2["yp"].Data().([]float64)[i]) > 1e-9 {
+			t.Fatal("changing a masked key changed the output")
+		}
+	}
+
+	y := T.New(T.WithShape(2, 12), T.WithBacking(data))
+	solver := G.NewAdamSolver(G.WithLearnRate(0.01))
+	firstLoss, err := model.FitBatch(NamedTs{"q": q, "kv": kv, "mask": mask}, NamedTs{"yt": y}, solver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model.Fit(NamedTs{"q": q, "kv": kv, "mask": mask}, NamedTs{"yt": y}, solver, WithEpochs(50), WithVerbose(false)); err != nil {
+		t.Fatal(err)
+	}
+	lastLoss, err := model.FitBatch(NamedTs{"q": q, "kv": kv, "mask": mask}, NamedTs{"yt": y}, solver)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastLoss >= firstLoss {
+		t.Fatalf("loss did not decrease, started at %v and ended at %v", firstLoss, lastLoss)
+	}
+
+	model2, err := makeAttentionModel()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := model2.BindParamsFrom(model); err != nil {
+		t.Fatal(err)
+	}
+	yps, err = model.Predict(NamedTs{"q": q, "kv": kv, "mask": mask})
+	if err != nil {
+		t.Fatal(err)
+	}
+	yps2, err = model2.Predict(NamedTs{"q": q, "kv": kv, "mask": mask})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !yps["yp"].Eq(yps2["yp"]) {
+		t.Fatal("model with bound params gave a different output")
+	}
+}
+
+func TestMasking(t *testing.T) {
+	model := NewModel()
+	inputs := Input(model, "input", T.Float64, 2, 4, 3).Node()
+	masked := Masking(model, "masking", 0).MustAttach(inputs)
+	if MaskOf(masked) == nil {
+		t.Fatal("expected the masking layer to give its output a mask")
+	}
+	lstm := LSTM(model, "lstm", 3)
+	lstm.ReturnSequences = true
+	sequence := lstm.MustAttach(masked)
+	final := GRU(model, "gru", 2).MustAttach(sequence)
+	attended := MultiHeadAttention(model, "attention", 2, 2).MustAttach(masked)
+	avgPooled := GlobalAveragePooling1D(model, "avgpool").MustAttach(attended)
+	maxPooled := GlobalMaxPooling1D(model, "maxpool").MustAttach(attended)
+	combined := Concatenate(model, "concatenate", 1).MustAttach(final, avgPooled, maxPooled)
+	model.MustBuild(
+		WithInput("x", inputs),
+		WithOutput("sequence", sequence),
+		WithOutput("final", final),
+		WithOutput("avg", avgPooled),
+		WithOutput("max", maxPooled),
+		WithLoss(MSELoss("yt", combined)),
+	)
+
+	// The second sample is the first one moved along by a timestep, so the padding is in different places
+	x := T.New(T.WithShape(2, 4, 3), T.WithBacking([]float64{
+		1, 2, 3, -1, 0.5, 2, 0, 0, 0, 0, 0, 0,
+		0, 0, 0, 1, 2, 3, -1, 0.5, 2, 0, 0, 0,
+	}))
+	ys := model.MustPredictBatch(NamedTs{"x": x})
+	for _, name := range []string{"final", "avg", "max"} {
+		data := ys[name].Data().([]float64)
+		half := len(data) / 2
+		for i := 0; i < half; i++ {
+			if math.Abs(data[i]-data[half+i]) > 1e-10 {
+				t.Fatalf("expected %s to ignore the padding, but the samples gave %v", name, ys[name])
+			}
+		}
+	}
+	sequenceData := ys["sequence"].Data().([]float64)
+	for _, i := range []int{6, 7, 8, 9, 10, 11, 12, 13, 14, 21, 22, 23} {
+		if sequenceData[i] != 0 {
+			t.Fatalf("expected the padded timesteps of the sequence to be zero, got %v", ys["sequence"])
+		}
+	}
+	// The masks should also be used when predicting with a different batch size
+	x0, _ := sliceBatch(x, T.S(0, 1))
+	if y0 := model.MustPredictBatch(NamedTs{"x": x0}); math.Abs(y0["final"].Data().([]float64)[0]-ys["final"].Data().([]float64)[0]) > 1e-10 {
+		t.Fatalf("expected the same output with a batch size of 1, got %v", y0["final"])
+	}
+
+	// The targets of padded timesteps should not change the loss
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 2, 4, 3).Node()
+	masked = Masking(model, "masking", 0).MustAttach(inputs)
+	lstm = LSTM(model, "lstm", 3)
+	lstm.ReturnSequences = true
+	sequence = lstm.MustAttach(masked)
+	model.MustBuild(WithInput("x", inputs), WithOutput("yp", sequence), WithLoss(MSELoss("yt", sequence)))
+	solver := G.NewVanillaSolver(G.WithLearnRate(0))
+	yt := T.New(T.WithShape(2, 4, 3), T.Of(T.Float64))
+	loss := model.MustFitBatch(NamedTs{"x": x}, NamedTs{"yt": yt}, solver)
+	for _, i := range []int{6, 10, 13, 23} {
+		yt.Data().([]float64)[i] = 100
+	}
+	if paddedLoss := model.MustFitBatch(NamedTs{"x": x}, NamedTs{"yt": yt}, solver); math.Abs(loss-paddedLoss) > 1e-10 {
+		t.Fatalf("expected the padded targets to be ignored, but the loss changed from %v to %v", loss, paddedLoss)
+	}
+
+	// CCE should only average over the timesteps that are not padding
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 1, 3, 2).Node()
+	outputs := Masking(model, "masking", 0).MustAttach(inputs)
+	outputs = Softmax(model, "softmax").MustAttach(outputs)
+	model.MustBuild(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(CCELoss("yt", outputs)))
+	x = T.New(T.WithShape(1, 3, 2), T.WithBacking([]float64{1, 2, 0, 0, 3, 1}))
+	yt = T.New(T.WithShape(1, 3, 2), T.WithBacking([]float64{0, 1, 1, 0, 1, 0}))
+	loss = model.MustFitBatch(NamedTs{"x": x}, NamedTs{"yt": yt}, solver)
+	expected := -(math.Log(math.Exp(2)/(math.Exp(1)+math.Exp(2))) + math.Log(math.Exp(3)/(math.Exp(3)+math.Exp(1)))) / 2
+	if math.Abs(loss-expected) > 1e-10 {
+		t.Fatalf("expected a CCE loss of %v, got %v", expected, loss)
+	}
+
+	// The masks should be forgotten even if the model fails to build
+	model = NewModel()
+	inputs = Input(model, "input", T.Float64, 1, 3, 2).Node()
+	outputs = Masking(model, "masking", 0).MustAttach(inputs)
+	if err := model.Build(WithInput("x", inputs), WithOutput("yp", outputs)); err == nil {
+		t.Fatal("expected an error building without a loss")
+	}
+	if MaskOf(outputs) != nil {
+		t.Fatal("expected the masks to be forgotten after a failed build")
+	}
+}
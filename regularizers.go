@@ -0,0 +1,97 @@
+package goras
+
+import (
+	"fmt"
+
+	G "gorgonia.org/gorgonia"
+)
+
+// Regularizer is a function that takes a node (e.g. the weights or output of a layer) and returns a scalar penalty to add to the loss.
+// Regularizers can be set on layers with weights, and Model.Build will add them to the loss automatically.
+type Regularizer func(x *G.Node) (*G.Node, error)
+
+// L1 creates a regularizer that adds l1 * sum(abs(x)) to the loss.
+func L1(l1 float64) Regularizer { return L1L2(l1, 0) }
+
+// L2 creates a regularizer that adds l2 * sum(square(x)) to the loss.
+func L2(l2 float64) Regularizer { return L1L2(0, l2) }
+
+// L1L2 creates a regularizer that adds l1 * sum(abs(x)) + l2 * sum(square(x)) to the loss.
+func L1L2(l1, l2 float64) Regularizer {
+	return func(x *G.Node) (*G.Node, error) {
+		var total *G.Node
+		for _, term := range []struct {
+			name   string
+			scale  float64
+			elemFn func(*G.Node) (*G.Node, error)
+		}{{"l1", l1, G.Abs}, {"l2", l2, G.Square}} {
+			if term.scale == 0 {
+				continue
+			}
+			penalty, err := term.elemFn(x)
+			if err != nil {
+				return nil, err
+			}
+			penalty, err = G.Sum(penalty, allAxes(x.Shape())...)
+			if err != nil {
+				return nil, err
+			}
+			scale := G.NewConstant(castVal(x.Dtype(), term.scale), G.WithName(fmt.Sprintf("%v.%v_scale", x.Name(), term.name)))
+			penalty, err = G.Mul(penalty, scale)
+			if err != nil {
+				return nil, err
+			}
+			if total == nil {
+				total = penalty
+			} else if total, err = G.Add(total, penalty); err != nil {
+				return nil, err
+			}
+		}
+		if total == nil {
+			return nil, fmt.Errorf("regularizer of %v must have a non-zero l1 or l2", x.Name())
+		}
+		return total, nil
+	}
+}
+
+// layerRegularizationTerms creates the regularization terms for a layer.
+// The kernel regularizer is applied to each of the kernels and the results are added together, under the name "kernel".
+// The activity regularizer is applied to the output, under the name "activity". It is divided by the batch size so that it does not depend on the batch size.
+// Either regularizer can be nil, in which case that term is left out.
+func layerRegularizationTerms(kernelRegularizer Regularizer, kernels []*G.Node, activityRegularizer Regularizer, output *G.Node) (map[string]*G.Node, error) {
+	terms := make(map[string]*G.Node)
+	if kernelRegularizer != nil {
+		var total *G.Node
+		for _, k := range kernels {
+			if k == nil {
+				return nil, fmt.Errorf("cannot regularize a layer before it is attached")
+			}
+			penalty, err := kernelRegularizer(k)
+			if err != nil {
+				return nil, err
+			}
+			if total == nil {
+				total = penalty
+			} else if total, err = G.Add(total, penalty); err != nil {
+				return nil, err
+			}
+		}
+		terms["kernel"] = total
+	}
+	if activityRegularizer != nil {
+		if output == nil {
+			return nil, fmt.Errorf("cannot regularize a layer before it is attached")
+		}
+		penalty, err := activityRegularizer(output)
+		if err != nil {
+			return nil, err
+		}
+		batchSize := G.NewConstant(castVal(output.Dtype(), float64(output.Shape()[0])), G.WithName(output.Name()+".activity_batch_size"))
+		penalty, err = G.Div(penalty, batchSize)
+		if err != nil {
+			return nil, err
+		}
+		terms["activity"] = penalty
+	}
+	return terms, nil
+}
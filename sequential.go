@@ -0,0 +1,89 @@
+package goras
+
+import (
+	"fmt"
+
+	G "gorgonia.org/gorgonia"
+	T "gorgonia.org/tensor"
+)
+
+// SingleInputLayer is a layer that can be attached to the output of a single previous layer.
+// This is true of most layers, such as Dense, Conv2D and all activations.
+type SingleInputLayer interface {
+	Layer
+	Attach(*G.Node) (*G.Node, error)
+}
+
+// LayerSpec creates a layer with the given name on a model, ready to be attached.
+// These are used by Sequential, which picks the names and attaches the layers for you.
+// Most of the time you can make one by passing a layer constructor to Seq, Seq1, Seq2 or Seq3, but you can also write your own:
+//
+//	func(m *Model, name string) SingleInputLayer { return Softmax(m, name, 2) }
+type LayerSpec func(m *Model, name string) SingleInputLayer
+
+// Seq creates a LayerSpec from a layer constructor that takes no arguments other than the model and name, such as Relu or Flatten.
+func Seq[L SingleInputLayer](constructor func(*Model, string) L) LayerSpec {
+	return func(m *Model, name string) SingleInputLayer { return constructor(m, name) }
+}
+
+// Seq1 creates a LayerSpec from a layer constructor that takes one argument after the model and name, such as Seq1(Dense, 10).
+func Seq1[L SingleInputLayer, A any](constructor func(*Model, string, A) L, a A) LayerSpec {
+	return func(m *Model, name string) SingleInputLayer { return constructor(m, name, a) }
+}
+
+// Seq2 creates a LayerSpec from a layer constructor that takes two arguments after the model and name, such as Seq2(SimpleConv2D, 3, 16).
+func Seq2[L SingleInputLayer, A, B any](constructor func(*Model, string, A, B) L, a A, b B) LayerSpec {
+	return func(m *Model, name string) SingleInputLayer { return constructor(m, name, a, b) }
+}
+
+// Seq3 creates a LayerSpec from a layer constructor that takes three arguments after the model and name.
+func Seq3[L SingleInputLayer, A, B, C any](constructor func(*Model, string, A, B, C) L, a A, b B, c C) LayerSpec {
+	return func(m *Model, name string) SingleInputLayer { return constructor(m, name, a, b, c) }
+}
+
+// Sequential creates and builds a model that is a linear stack of layers, where each layer is attached to the output of the one before it.
+// The layers are named using NewNamer(name), starting with the input layer, which has the given dtype and shape (including the batch size).
+// The model is built in the same way as the examples, so it is an ordinary model where:
+//   - The input is called "x"
+//   - The output is called "yp"
+//   - The loss is created by calling loss("yt", output), so the targets are called "yt". This can be MSELoss, BCELoss, or CCELoss.
+//
+// For example:
+//
+//	model, err := Sequential("model", T.Float64, []int{4, 2}, MSELoss, Seq1(Dense, 5), Seq(Sigmoid), Seq1(Dense, 1), Seq(Sigmoid))
+func Sequential(name string, dtype T.Dtype, inputShape []int, loss func(targetName string, output *G.Node) LossFunc, layers ...LayerSpec) (*Model, error) {
+	if len(layers) == 0 {
+		return nil, fmt.Errorf("a sequential model needs at least one layer")
+	}
+	if loss == nil {
+		return nil, fmt.Errorf("a sequential model needs a loss")
+	}
+	model := NewModel()
+	n := NewNamer(name)
+	inputs := Input(model, n(), dtype, inputShape...).Node()
+	outputs := inputs
+	for _, spec := range layers {
+		layer := spec(model, n())
+		var err error
+		outputs, err = layer.Attach(outputs)
+		if err != nil {
+			// The model will not be built, so its masks have to be forgotten here
+			forgetMasks(model.Graph)
+			return nil, fmt.Errorf("error attaching layer %s: %v", layer.Name(), err)
+		}
+	}
+	err := model.Build(WithInput("x", inputs), WithOutput("yp", outputs), WithLoss(loss("yt", outputs)))
+	if err != nil {
+		return nil, err
+	}
+	return model, nil
+}
+
+// MustSequential calls Sequential, but panics if there is an error.
+func MustSequential(name string, dtype T.Dtype, inputShape []int, loss func(targetName string, output *G.Node) LossFunc, layers ...LayerSpec) *Model {
+	model, err := Sequential(name, dtype, inputShape, loss, layers...)
+	if err != nil {
+		panic(err)
+	}
+	return model
+}
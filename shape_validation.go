@@ -20,7 +20,7 @@ func validateShape(shape T.Shape, vals ...shapeValidator) error {
 func valNDims(n int) shapeValidator {
 	return func(s T.Shape) error {
 		if len(s) != n {
-			return fmt.Errorf("expected shape with ndims %v but got ndims %v with shape %v", len(s), n, s)
+			return fmt.Errorf("expected shape with ndims %v but got ndims %v with shape %v", n, len(s), s)
 		}
 		return nil
 	}
@@ -35,6 +35,15 @@ func valNthDim(dim int, val int) shapeValidator {
 	}
 }
 
+func valNthDimDivisibleBy(dim int, val int) shapeValidator {
+	return func(s T.Shape) error {
+		if s[dim]%val != 0 {
+			return fmt.Errorf("expected shape[%v] to be divisible by %v but got %v", dim, val, s[dim])
+		}
+		return nil
+	}
+}
+
 func valMatchingDim(target T.Shape) shapeValidator {
 	return func(s T.Shape) error {
 		if !s.Eq(target) {
@@ -62,6 +71,15 @@ func valAtLeastNDims(n int) shapeValidator {
 	}
 }
 
+func valAtMostNDims(n int) shapeValidator {
+	return func(s T.Shape) error {
+		if len(s) > n {
+			return fmt.Errorf("expected shape with at most %v dims but got %v", n, len(s))
+		}
+		return nil
+	}
+}
+
 func checkBatchedInputShapes(m *Model, inps map[string]T.Tensor) error {
 	if len(inps) != len(m.InputNodes) {
 		return fmt.Errorf("incorrect number of inputs. expected %v but got %v", len(m.InputNodes), len(inps))
@@ -78,6 +96,30 @@ func checkBatchedInputShapes(m *Model, inps map[string]T.Tensor) error {
 	return nil
 }
 
+// checkInputShapesExceptBatch checks that the inputs match the input nodes of the model, apart from the batch size (the first dim).
+// Every input must have the same batch size, which is returned.
+func checkInputShapesExceptBatch(m *Model, inps map[string]T.Tensor) (int, error) {
+	if len(inps) != len(m.InputNodes) {
+		return 0, fmt.Errorf("incorrect number of inputs. expected %v but got %v", len(m.InputNodes), len(inps))
+	}
+	batchSize := -1
+	for name := range inps {
+		if _, ok := m.InputNodes[name]; !ok {
+			return 0, fmt.Errorf("input %v not found in model", name)
+		}
+		expected, got := m.InputNodes[name].Shape(), inps[name].Shape()
+		if len(got) != len(expected) || len(got) == 0 || !exactShapeEq(expected[1:], got[1:]) || got[0] < 1 {
+			return 0, fmt.Errorf("input %v had incorrect shape. expected %v (with any batch size) but got %v", name, expected, got)
+		}
+		if batchSize == -1 {
+			batchSize = got[0]
+		} else if batchSize != got[0] {
+			return 0, fmt.Errorf("all inputs must have the same batch size")
+		}
+	}
+	return batchSize, nil
+}
+
 func checkBatchedLossRequirementShapes(m *Model, outs map[string]T.Tensor) error {
 	if len(outs) != len(m.LossRequiredNodes) {
 		return fmt.Errorf("incorrect number of loss requirements. expected %v but got %v", len(m.LossRequiredNodes), len(outs))
@@ -3,6 +3,8 @@ package goras
 import (
 	"reflect"
 
+	G "gorgonia.org/gorgonia"
+
 	"gorgonia.org/tensor"
 	T "gorgonia.org/tensor"
 )
@@ -29,3 +31,35 @@ func allAxes(shape tensor.Shape) []int {
 	}
 	return axes
 }
+
+// filledInts returns a slice of n copies of v, such as a stride or dilation of 1 for every spatial dim.
+func filledInts(n, v int) []int {
+	s := make([]int, n)
+	for i := range s {
+		s[i] = v
+	}
+	return s
+}
+
+// castVal converts v to a scalar of the given dtype, so it can be used to make constants.
+func castVal(dtype T.Dtype, v float64) interface{} {
+	switch dtype {
+	case T.Float64:
+		return v
+	case T.Float32:
+		return float32(v)
+	case T.Int:
+		return int(v)
+	default:
+		panic("type is not implemented to be castable. please open an issue so i will fix")
+	}
+}
+
+// affine computes x*w + b, where x is a matrix and b is a vector that is broadcast over the first axis.
+func affine(x, w, b *G.Node) (*G.Node, error) {
+	xw, err := G.Mul(x, w)
+	if err != nil {
+		return nil, err
+	}
+	return G.BroadcastAdd(xw, b, nil, []byte{0})
+}